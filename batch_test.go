@@ -1,7 +1,10 @@
 package simpleflow
 
 import (
+	"context"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -96,6 +99,167 @@ func (s *BatchSuite) TestBatchChan() {
 
 }
 
+func (s *BatchSuite) TestBatchChanCtx() {
+	ctx := context.Background()
+
+	s.Run("fractional number of batches", func() {
+		items := make(chan int, 10)
+		LoadChannel(items, generateSeries(10)...)
+		close(items)
+		out := make(chan []int, 2)
+		n, err := BatchChanCtx(ctx, items, 6, out)
+		s.NoError(err)
+		s.Equal(10, n)
+		close(out)
+		s.Equal([][]int{{0, 1, 2, 3, 4, 5}, {6, 7, 8, 9}}, ChannelToSlice(out))
+	})
+
+	s.Run("zero batch size", func() {
+		n, err := BatchChanCtx(ctx, make(chan int), 0, make(chan []int))
+		s.NoError(err)
+		s.Equal(0, n)
+	})
+
+	s.Run("cancelled while sending", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		items := make(chan int, 6)
+		LoadChannel(items, generateSeries(5)...)
+		close(items)
+
+		// Cancel once the first batch is received so that the remaining batches are never sent
+		out := make(chan []int)
+		var received [][]int
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			received = append(received, <-out)
+			cancel()
+		}()
+		n, err := BatchChanCtx(ctx, items, 2, out)
+		<-done
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(2, n)
+		s.Equal([][]int{{0, 1}}, received)
+	})
+
+	s.Run("cancelled with an incomplete batch", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		items := make(chan int, 3)
+		LoadChannel(items, 1, 2, 3)
+		out := make(chan []int, 1)
+		go func() {
+			for len(items) > 0 {
+				runtime.Gosched()
+			}
+			cancel()
+		}()
+		n, err := BatchChanCtx(ctx, items, 2, out)
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(2, n)
+		close(out)
+		s.Equal([][]int{{1, 2}}, ChannelToSlice(out))
+	})
+}
+
+func (s *BatchSuite) TestBatchChanWithLinger() {
+	ctx := context.Background()
+
+	// run starts BatchChanWithLinger in the background and returns a channel with its result
+	type result struct {
+		n   int
+		err error
+	}
+	run := func(ctx context.Context, items chan int, size int, linger time.Duration, flush chan struct{}, out chan []int) chan result {
+		done := make(chan result, 1)
+		go func() {
+			n, err := BatchChanWithLinger(ctx, items, size, linger, flush, out)
+			done <- result{n: n, err: err}
+		}()
+		return done
+	}
+
+	s.Run("full batches", func() {
+		items := make(chan int, 5)
+		LoadChannel(items, generateSeries(5)...)
+		close(items)
+		out := make(chan []int, 3)
+		n, err := BatchChanWithLinger(ctx, items, 2, time.Hour, nil, out)
+		s.NoError(err)
+		s.Equal(5, n)
+		close(out)
+		s.Equal([][]int{{0, 1}, {2, 3}, {4}}, ChannelToSlice(out))
+	})
+
+	s.Run("linger", func() {
+		items := make(chan int)
+		out := make(chan []int)
+		done := run(ctx, items, 10, 10*time.Millisecond, nil, out)
+
+		// The batches are pushed without being full since the input is idle
+		items <- 1
+		s.Equal([]int{1}, <-out)
+		items <- 2
+		items <- 3
+		s.Equal([]int{2, 3}, <-out)
+		close(items)
+		r := <-done
+		s.NoError(r.err)
+		s.Equal(3, r.n)
+	})
+
+	s.Run("flush", func() {
+		items := make(chan int)
+		flush := make(chan struct{})
+		out := make(chan []int)
+		done := run(ctx, items, 10, 0, flush, out)
+
+		// Flushing an empty batch does nothing
+		flush <- struct{}{}
+		items <- 1
+		items <- 2
+		flush <- struct{}{}
+		s.Equal([]int{1, 2}, <-out)
+
+		// A closed flush channel is ignored
+		close(flush)
+		items <- 3
+		close(items)
+		s.Equal([]int{3}, <-out)
+		r := <-done
+		s.NoError(r.err)
+		s.Equal(3, r.n)
+	})
+
+	s.Run("cancelled", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		items := make(chan int)
+		out := make(chan []int)
+		done := run(ctx, items, 2, 0, nil, out)
+		items <- 1
+		items <- 2
+		s.Equal([]int{1, 2}, <-out)
+		items <- 3
+		cancel()
+		r := <-done
+		s.ErrorIs(r.err, context.Canceled)
+		s.Equal(2, r.n)
+	})
+
+	s.Run("cancelled while sending", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		items := make(chan int, 3)
+		LoadChannel(items, 1, 2, 3)
+		out := make(chan []int)
+		done := run(ctx, items, 2, 0, nil, out)
+		s.Equal([]int{1, 2}, <-out)
+		close(items)
+		cancel()
+		r := <-done
+		s.ErrorIs(r.err, context.Canceled)
+		s.Equal(2, r.n)
+	})
+}
+
 func (s *BatchSuite) TestMapSlice() {
 	items := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9}
 
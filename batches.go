@@ -1,5 +1,10 @@
 package simpleflow
 
+import (
+	"context"
+	"time"
+)
+
 // BatchSlice takes a slice and breaks it up into sub-slices of `size` length each
 func BatchSlice[T any](items []T, size int) [][]T {
 	if size == 0 || len(items) == 0 {
@@ -59,6 +64,127 @@ func BatchChan[T any](items <-chan T, size int, to chan []T) {
 	return
 }
 
+// BatchChanCtx reads from a channel and pushes batches of size `size` onto the `to` channel until `items` is closed or
+// the context is cancelled. It returns the number of items in the batches that were pushed, along with the context
+// error if the context was cancelled before `items` was closed. The items of an incomplete batch are dropped when the
+// context is cancelled.
+func BatchChanCtx[T any](ctx context.Context, items <-chan T, size int, to chan<- []T) (int, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	var n int
+	batch := make([]T, 0, size)
+	for {
+		v, ok, err := recvCtx(ctx, items)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			break
+		}
+		batch = append(batch, v)
+
+		if len(batch) == size {
+			if !sendCtx(ctx, to, batch) {
+				return n, ctx.Err()
+			}
+			n += len(batch)
+			batch = make([]T, 0, size)
+		}
+	}
+	if len(batch) > 0 {
+		if !sendCtx(ctx, to, batch) {
+			return n, ctx.Err()
+		}
+		n += len(batch)
+	}
+
+	return n, nil
+}
+
+// BatchChanWithLinger reads from a channel and pushes batches of up to `size` items onto the `to` channel until
+// `items` is closed or the context is cancelled. A batch is pushed once it is full, once its first item has waited
+// for `linger`, or when a value is received on `flush`, whichever comes first. A `linger` of 0 or a nil `flush`
+// channel disables the respective trigger, and `flush` is ignored once it is closed. A `size` less than 1 is treated
+// as 1.
+//
+// It returns the number of items in the batches that were pushed, along with the context error if the context was
+// cancelled before `items` was closed. The items of an incomplete batch are dropped when the context is cancelled.
+func BatchChanWithLinger[T any](ctx context.Context, items <-chan T, size int, linger time.Duration, flush <-chan struct{}, to chan<- []T) (int, error) {
+	var n int
+	_, err := collectBatches(ctx, items, size, linger, flush, func(batch []T) bool {
+		if !sendCtx(ctx, to, batch) {
+			return false
+		}
+		n += len(batch)
+		return true
+	})
+	return n, err
+}
+
+// collectBatches reads from `items` and calls `push` with batches of up to `size` items, following the triggers of
+// BatchChanWithLinger. `push` returns false if the context was cancelled before the batch was pushed. It returns the
+// items of the batch that was not pushed, along with the context error if the context was cancelled before `items`
+// was closed.
+func collectBatches[T any](ctx context.Context, items <-chan T, size int, linger time.Duration, flush <-chan struct{}, push func([]T) bool) ([]T, error) {
+	if size < 1 {
+		size = 1
+	}
+	var batch []T
+	// timeout fires once the first item of the batch has waited for `linger`. It is nil while the batch is empty or
+	// there is no linger.
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		// Check for cancellation first since select picks randomly between ready cases
+		if err := ctx.Err(); err != nil {
+			return batch, err
+		}
+		select {
+		case v, ok := <-items:
+			if !ok {
+				if len(batch) > 0 && !push(batch) {
+					return batch, ctx.Err()
+				}
+				return nil, nil
+			}
+			if len(batch) == 0 && linger > 0 {
+				timer = time.NewTimer(linger)
+				timeout = timer.C
+			}
+			batch = append(batch, v)
+			if len(batch) < size {
+				continue
+			}
+		case <-timeout:
+		case _, ok := <-flush:
+			if !ok {
+				flush = nil
+				continue
+			}
+			if len(batch) == 0 {
+				continue
+			}
+		case <-ctx.Done():
+			return batch, ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if !push(batch) {
+			return batch, ctx.Err()
+		}
+		batch = nil
+	}
+}
+
 // IncrementalBatchSlice incrementally builds slice batches of size `batchSize` by appending to a slice
 // If the slice is larger than `batchSize` elements, a single batch is returned. The remaining
 // elements of the slice are always returned. Batched items are returned from the head of the slice.
@@ -0,0 +1,188 @@
+package simpleflow
+
+import (
+	"context"
+	"sync"
+)
+
+// Broadcaster reads values from a channel and sends each value to all of its subscribers. Subscribers can subscribe
+// and unsubscribe while the broadcaster is running.
+type Broadcaster[T any] struct {
+	ctx    context.Context
+	replay int
+	done   chan struct{}
+
+	// mu guards the fields below. `history` holds the last values read, up to `replay` values, and `closed` is set
+	// once the broadcaster stops reading values.
+	mu      sync.Mutex
+	history []T
+	subs    map[<-chan T]*subscription[T]
+	closed  bool
+}
+
+// subscription is a subscriber of a Broadcaster
+type subscription[T any] struct {
+	*Subscriber[T]
+	out chan T
+	// ctx is cancelled when the subscriber unsubscribes, which abandons the values that are being delivered to it
+	ctx    context.Context
+	cancel context.CancelFunc
+	// forwarded is closed once the go routine sending the ring buffer of a DropOldest policy exits. It is nil for
+	// other policies.
+	forwarded chan struct{}
+
+	// sendMu is held while a value is delivered so that the channel is not closed during delivery
+	sendMu sync.Mutex
+	closed bool
+}
+
+// NewBroadcaster starts a Broadcaster which reads values from `from` until it is closed or the context is cancelled.
+// The last `replay` values are sent to each new subscriber before any new values. Once the broadcaster stops, the
+// channels of all subscribers are closed. When `from` is closed, the values held in the ring buffers of subscribers
+// with a DropOldest policy are delivered first, while they are dropped when the context is cancelled.
+func NewBroadcaster[T any](ctx context.Context, from <-chan T, replay int) *Broadcaster[T] {
+	b := &Broadcaster[T]{
+		ctx:    ctx,
+		replay: replay,
+		done:   make(chan struct{}),
+		subs:   make(map[<-chan T]*subscription[T]),
+	}
+	go b.run(from)
+	return b
+}
+
+// Subscribe returns a new channel which receives the values read by the broadcaster, starting with the values to
+// replay. The channel has a buffer of `buffer` values in addition to the values to replay, and `policy` determines
+// what happens when the channel is not ready to receive a value. If the broadcaster has stopped, the channel only
+// receives the values to replay and is closed.
+func (b *Broadcaster[T]) Subscribe(buffer int, policy DeliveryPolicy) <-chan T {
+	if buffer < 0 {
+		buffer = 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The channel has room for the values to replay so that they are sent before any new value is read
+	out := make(chan T, buffer+len(b.history))
+	for _, v := range b.history {
+		out <- v
+	}
+	if b.closed {
+		close(out)
+		return out
+	}
+
+	sub := &subscription[T]{Subscriber: NewSubscriber[T](out, policy), out: out}
+	sub.ctx, sub.cancel = context.WithCancel(b.ctx)
+	if policy.mode == deliverDropOldest {
+		sub.forwarded = make(chan struct{})
+		go func() {
+			defer close(sub.forwarded)
+			sub.forward(sub.ctx)
+		}()
+	}
+	b.subs[out] = sub
+	return out
+}
+
+// Unsubscribe stops sending values to a channel returned by Subscribe and closes it. The values that were not
+// delivered to the channel yet are dropped. It has no effect if the channel is already unsubscribed or closed.
+func (b *Broadcaster[T]) Unsubscribe(ch <-chan T) {
+	b.mu.Lock()
+	sub, ok := b.subs[ch]
+	b.mu.Unlock()
+	if !ok {
+		return
+	}
+	sub.cancel()
+	b.closeSubscription(sub)
+}
+
+// Done returns a channel that is closed once the broadcaster stops and the channels of all subscribers are closed
+func (b *Broadcaster[T]) Done() <-chan struct{} {
+	return b.done
+}
+
+// run sends the values read from `from` to the subscribers and closes their channels once it stops
+func (b *Broadcaster[T]) run(from <-chan T) {
+	defer close(b.done)
+	for {
+		v, ok, err := recvCtx(b.ctx, from)
+		if err != nil || !ok {
+			break
+		}
+
+		// Record the value and take the subscribers together so that a new subscriber either receives the value
+		// as a replay or from delivery, but not both
+		b.mu.Lock()
+		if b.replay > 0 {
+			if len(b.history) == b.replay {
+				b.history = b.history[1:]
+			}
+			b.history = append(b.history, v)
+		}
+		subs := b.subscriptions()
+		b.mu.Unlock()
+
+		for _, sub := range subs {
+			b.deliver(sub, v)
+		}
+	}
+
+	b.mu.Lock()
+	b.closed = true
+	subs := b.subscriptions()
+	b.mu.Unlock()
+	// The ring buffers of the subscribers are drained before their channels are closed
+	for _, sub := range subs {
+		b.closeSubscription(sub)
+	}
+}
+
+// deliver sends the value to the subscriber according to its policy
+func (b *Broadcaster[T]) deliver(sub *subscription[T], v T) {
+	sub.sendMu.Lock()
+	defer sub.sendMu.Unlock()
+	if sub.closed {
+		return
+	}
+	sub.Subscriber.deliver(sub.ctx, v)
+
+	// The channel of a disconnected subscriber is closed by its policy
+	if sub.Disconnected() {
+		sub.closed = true
+		b.mu.Lock()
+		delete(b.subs, sub.out)
+		b.mu.Unlock()
+	}
+}
+
+// closeSubscription waits for the ring buffer of the subscriber to be drained, unless its context is cancelled, then
+// closes its channel and removes it from the subscribers
+func (b *Broadcaster[T]) closeSubscription(sub *subscription[T]) {
+	sub.finish()
+	if sub.forwarded != nil {
+		<-sub.forwarded
+	}
+
+	sub.sendMu.Lock()
+	if !sub.closed {
+		sub.closed = true
+		close(sub.out)
+	}
+	sub.sendMu.Unlock()
+	sub.cancel()
+
+	b.mu.Lock()
+	delete(b.subs, sub.out)
+	b.mu.Unlock()
+}
+
+// subscriptions returns the current subscribers. It must be called with `mu` held.
+func (b *Broadcaster[T]) subscriptions() []*subscription[T] {
+	subs := make([]*subscription[T], 0, len(b.subs))
+	for _, sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
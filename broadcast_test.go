@@ -0,0 +1,145 @@
+package simpleflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BroadcastSuite struct {
+	suite.Suite
+}
+
+func TestBroadcast(t *testing.T) {
+	s := new(BroadcastSuite)
+	suite.Run(t, s)
+}
+
+// receive reads `n` values from the channel
+func receive[T any](ch <-chan T, n int) []T {
+	values := make([]T, n)
+	for ii := range values {
+		values[ii] = <-ch
+	}
+	return values
+}
+
+// drain reads the values from the channel until it is closed
+func drain[T any](ch <-chan T) []T {
+	values, _ := ChannelToSliceCtx(context.Background(), ch)
+	return values
+}
+
+func (s *BroadcastSuite) TestBroadcast() {
+	ctx := context.Background()
+	source := make(chan int)
+	b := NewBroadcaster(ctx, source, 0)
+	sub1 := b.Subscribe(5, BlockOnFull())
+	sub2 := b.Subscribe(5, BlockOnFull())
+
+	LoadChannel(source, 1, 2, 3)
+	close(source)
+	<-b.Done()
+
+	// The subscriber channels are closed once the source is closed
+	s.Equal([]int{1, 2, 3}, drain(sub1))
+	s.Equal([]int{1, 2, 3}, drain(sub2))
+}
+
+func (s *BroadcastSuite) TestReplay() {
+	ctx := context.Background()
+	source := make(chan int)
+	b := NewBroadcaster(ctx, source, 2)
+	early := b.Subscribe(0, BlockOnFull())
+
+	// Each value is recorded before it is delivered, so it can be replayed once it is received
+	go LoadChannel(source, 1, 2, 3)
+	s.Equal([]int{1, 2, 3}, receive(early, 3))
+
+	late := b.Subscribe(1, BlockOnFull())
+	go LoadChannel(source, 4)
+	s.Equal([]int{4}, receive(early, 1))
+	s.Equal([]int{2, 3, 4}, receive(late, 3))
+
+	close(source)
+	<-b.Done()
+	s.Empty(drain(early))
+	s.Empty(drain(late))
+
+	s.Run("subscribe after the source is closed", func() {
+		sub := b.Subscribe(-1, BlockOnFull())
+		s.Equal([]int{3, 4}, drain(sub))
+	})
+}
+
+func (s *BroadcastSuite) TestUnsubscribe() {
+	ctx := context.Background()
+	source := make(chan int)
+	b := NewBroadcaster(ctx, source, 0)
+	stuck := b.Subscribe(0, BlockOnFull())
+	active := b.Subscribe(5, BlockOnFull())
+
+	// The broadcaster is blocked on the subscriber that does not read until it unsubscribes
+	source <- 1
+	time.Sleep(10 * time.Millisecond)
+	b.Unsubscribe(stuck)
+	_, ok := <-stuck
+	s.False(ok)
+	s.Equal([]int{1}, receive(active, 1))
+
+	// Unsubscribing again or with an unknown channel has no effect
+	b.Unsubscribe(stuck)
+	b.Unsubscribe(make(chan int))
+
+	source <- 2
+	s.Equal([]int{2}, receive(active, 1))
+	b.Unsubscribe(active)
+	s.Empty(drain(active))
+
+	close(source)
+	<-b.Done()
+}
+
+func (s *BroadcastSuite) TestPolicies() {
+	ctx := context.Background()
+	source := make(chan int)
+	b := NewBroadcaster(ctx, source, 0)
+	active := b.Subscribe(0, BlockOnFull())
+	dropNewest := b.Subscribe(1, DropNewest())
+	dropOldest := b.Subscribe(0, DropOldest(2))
+	disconnect := b.Subscribe(0, DisconnectAfter(10*time.Millisecond))
+
+	// Only the active subscriber reads while the values are broadcast
+	go LoadChannel(source, 1, 2, 3, 4, 5)
+	s.Equal([]int{1, 2, 3, 4, 5}, receive(active, 5))
+
+	// The disconnected subscriber is closed without waiting for the source
+	_, ok := <-disconnect
+	s.False(ok)
+	b.Unsubscribe(disconnect)
+
+	// The ring buffer is drained before the channels are closed
+	close(source)
+	received := drain(dropOldest)
+	s.Equal([]int{4, 5}, received[len(received)-2:])
+	s.Equal([]int{1}, drain(dropNewest))
+	<-b.Done()
+}
+
+func (s *BroadcastSuite) TestCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan int)
+	b := NewBroadcaster(ctx, source, 0)
+	blocked := b.Subscribe(0, BlockOnFull())
+	ring := b.Subscribe(0, DropOldest(5))
+
+	source <- 1
+	cancel()
+	<-b.Done()
+
+	// The values that were not delivered are dropped
+	s.Empty(drain(blocked))
+	s.Empty(drain(ring))
+}
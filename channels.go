@@ -1,5 +1,7 @@
 package simpleflow
 
+import "context"
+
 // ChannelIntoSlice reads elements from the channel and returns appends them to the `out` slice.
 // This operation will block until the channel is closed
 func ChannelIntoSlice[T any](ch chan T, out []T) []T {
@@ -25,6 +27,31 @@ func LoadChannel[T any](ch chan<- T, items ...T) {
 	return
 }
 
+// LoadChannelCtx puts the elements of `items` onto the channel `ch` until all of them are sent or the context is
+// cancelled. It returns the number of elements that were sent, along with the context error if the context was
+// cancelled before all elements were sent.
+func LoadChannelCtx[T any](ctx context.Context, ch chan<- T, items ...T) (int, error) {
+	for ii := 0; ii < len(items); ii++ {
+		if !sendCtx(ctx, ch, items[ii]) {
+			return ii, ctx.Err()
+		}
+	}
+	return len(items), nil
+}
+
+// ChannelToSliceCtx reads elements from the channel until it is closed or the context is cancelled and returns them
+// as a slice. If the context is cancelled before the channel is closed, the elements read so far are returned along
+// with the context error.
+func ChannelToSliceCtx[T any](ctx context.Context, ch <-chan T) (out []T, err error) {
+	for {
+		v, ok, err := recvCtx(ctx, ch)
+		if err != nil || !ok {
+			return out, err
+		}
+		out = append(out, v)
+	}
+}
+
 // CloseMany closes all of the given channels
 func CloseMany[T any](channels ...chan T) {
 	for _, ch := range channels {
@@ -38,3 +65,32 @@ func CloseManyWriters[T any](channels ...chan<- T) {
 		close(ch)
 	}
 }
+
+// sendCtx sends `v` on the channel. It returns false if the context was cancelled before the value was sent.
+func sendCtx[T any](ctx context.Context, ch chan<- T, v T) bool {
+	// Check for cancellation first since select picks randomly between ready cases
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recvCtx receives a value from the channel. It returns false if the channel is closed, or the context error if the
+// context was cancelled before a value was received.
+func recvCtx[T any](ctx context.Context, ch <-chan T) (v T, ok bool, err error) {
+	// Check for cancellation first since select picks randomly between ready cases
+	if err := ctx.Err(); err != nil {
+		return v, false, err
+	}
+	select {
+	case v, ok = <-ch:
+		return v, ok, nil
+	case <-ctx.Done():
+		return v, false, ctx.Err()
+	}
+}
@@ -1,7 +1,9 @@
 package simpleflow
 
 import (
+	"context"
 	"github.com/stretchr/testify/suite"
+	"runtime"
 	"testing"
 )
 
@@ -26,3 +28,54 @@ func (s *ChannelsSuite) TestCloseMany() {
 	values = ChannelIntoSlice(ch2, values)
 	s.ElementsMatch([]int{1, 2}, values)
 }
+
+func (s *ChannelsSuite) TestLoadChannelCtx() {
+	ctx := context.Background()
+	ch := make(chan int, 3)
+	n, err := LoadChannelCtx(ctx, ch, 1, 2, 3)
+	s.NoError(err)
+	s.Equal(3, n)
+	close(ch)
+	s.Equal([]int{1, 2, 3}, ChannelToSlice(ch))
+
+	s.Run("cancelled while blocked", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		ch := make(chan int, 2)
+		go func() {
+			// Cancel once the channel buffer is full so that the next send blocks
+			for len(ch) < cap(ch) {
+				runtime.Gosched()
+			}
+			cancel()
+		}()
+		n, err := LoadChannelCtx(ctx, ch, 1, 2, 3, 4)
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(2, n)
+	})
+}
+
+func (s *ChannelsSuite) TestChannelToSliceCtx() {
+	ctx := context.Background()
+	ch := make(chan int, 3)
+	LoadChannel(ch, 1, 2, 3)
+	close(ch)
+	values, err := ChannelToSliceCtx(ctx, ch)
+	s.NoError(err)
+	s.Equal([]int{1, 2, 3}, values)
+
+	s.Run("cancelled before the channel is closed", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		ch := make(chan int, 3)
+		LoadChannel(ch, 1, 2)
+		go func() {
+			// Cancel once the buffered values are read so that the next receive blocks
+			for len(ch) > 0 {
+				runtime.Gosched()
+			}
+			cancel()
+		}()
+		values, err := ChannelToSliceCtx(ctx, ch)
+		s.ErrorIs(err, context.Canceled)
+		s.Equal([]int{1, 2}, values)
+	})
+}
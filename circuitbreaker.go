@@ -0,0 +1,236 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling a job while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets all calls through while counting their failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls with ErrCircuitOpen until the cool-down period has passed
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial calls through to decide whether to close or reopen the circuit
+	CircuitHalfOpen
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig describes when a CircuitBreaker opens and closes
+type CircuitBreakerConfig struct {
+	// FailureRatio is the ratio of failed calls, between 0 and 1, at which the circuit opens
+	FailureRatio float64
+	// MinCalls is the minimum number of calls before the failure ratio is evaluated. Values less than 1 are treated
+	// as 1.
+	MinCalls int
+	// Window is the period over which calls are counted while the circuit is closed. The counts are reset at the
+	// start of each window. Zero means calls are counted from the time the circuit closed.
+	Window time.Duration
+	// CoolDown is the time the circuit stays open before it lets trial calls through
+	CoolDown time.Duration
+	// HalfOpenCalls is the number of trial calls let through while half-open. The circuit closes once they all
+	// succeed and reopens as soon as one fails. Values less than 1 are treated as 1.
+	HalfOpenCalls int
+	// IsFailure determines whether an error counts as a failure. If nil, all errors are failures.
+	IsFailure func(error) bool
+	// OnStateChange is called whenever the state of the circuit changes. It is called while the breaker is locked
+	// and must not call the methods of the breaker.
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker stops calls to a failing dependency for a cool-down period once the ratio of failed calls reaches
+// a threshold. It is safe for concurrent use and can be shared between jobs and worker pools.
+type CircuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu    sync.Mutex
+	state CircuitState
+	// generation is incremented on each state change so that calls let through in a previous state are ignored
+	generation int
+	// since is the time at which the current window started, or the time at which the circuit opened
+	since     time.Time
+	calls     int
+	failures  int
+	inFlight  int
+	successes int
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker
+func NewCircuitBreaker(cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.MinCalls < 1 {
+		cfg.MinCalls = 1
+	}
+	if cfg.HalfOpenCalls < 1 {
+		cfg.HalfOpenCalls = 1
+	}
+	return &CircuitBreaker{cfg: cfg, since: time.Now()}
+}
+
+// State returns the current state of the circuit
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(time.Now())
+	return b.state
+}
+
+// Do calls `f` if the circuit allows it and records the result. It returns ErrCircuitOpen without calling `f` if the
+// circuit is open, or is half-open with all of its trial calls in progress. Errors returned after `ctx` is cancelled
+// are not counted.
+func (b *CircuitBreaker) Do(ctx context.Context, f func(context.Context) error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	// Count a panic as a failure so that a trial call is not held forever, without recovering it
+	finished := false
+	defer func() {
+		if !finished {
+			b.record(generation, true)
+		}
+	}()
+	err = f(ctx)
+	finished = true
+
+	if err != nil && ctx.Err() != nil {
+		b.release(generation)
+		return err
+	}
+	b.record(generation, err != nil && (b.cfg.IsFailure == nil || b.cfg.IsFailure(err)))
+	return err
+}
+
+// CircuitBreakerJob wraps the job `f` so that it is called through the circuit breaker
+func CircuitBreakerJob[T any](f Job[T], breaker *CircuitBreaker) Job[T] {
+	return func(ctx context.Context, item T) error {
+		return breaker.Do(ctx, func(ctx context.Context) error {
+			return f(ctx, item)
+		})
+	}
+}
+
+// CircuitBreakerJobKV wraps the job `f` so that it is called through the circuit breaker
+func CircuitBreakerJobKV[K comparable, V any](f JobKV[K, V], breaker *CircuitBreaker) JobKV[K, V] {
+	return func(ctx context.Context, k K, v V) error {
+		return breaker.Do(ctx, func(ctx context.Context) error {
+			return f(ctx, k, v)
+		})
+	}
+}
+
+// WithCircuitBreaker calls each attempt of the jobs of the worker pool through the circuit breaker. While the circuit
+// is open, jobs fail with ErrCircuitOpen without being called. When combined with WithRetry, use
+// RetryPolicy.Retryable to stop retrying on ErrCircuitOpen if needed.
+func WithCircuitBreaker(breaker *CircuitBreaker) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.breaker = breaker
+	}
+}
+
+// allow reports whether a call can be made and returns the generation that the call belongs to
+func (b *CircuitBreaker) allow() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(time.Now())
+
+	switch b.state {
+	case CircuitOpen:
+		return 0, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.inFlight+b.successes >= b.cfg.HalfOpenCalls {
+			return 0, ErrCircuitOpen
+		}
+		b.inFlight++
+	}
+	return b.generation, nil
+}
+
+// record counts the result of a call which was let through in `generation`
+func (b *CircuitBreaker) record(generation int, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	now := time.Now()
+
+	switch b.state {
+	case CircuitClosed:
+		b.expire(now)
+		b.calls++
+		if failed {
+			b.failures++
+		}
+		if failed && b.calls >= b.cfg.MinCalls && float64(b.failures)/float64(b.calls) >= b.cfg.FailureRatio {
+			b.setState(CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		b.inFlight--
+		if failed {
+			b.setState(CircuitOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenCalls {
+			b.setState(CircuitClosed, now)
+		}
+	}
+}
+
+// release frees the trial call of a call which was let through in `generation` without counting its result
+func (b *CircuitBreaker) release(generation int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation && b.state == CircuitHalfOpen {
+		b.inFlight--
+	}
+}
+
+// expire moves the circuit from open to half-open once the cool-down has passed and starts a new window of calls
+// while closed. It must be called with `mu` held.
+func (b *CircuitBreaker) expire(now time.Time) {
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.since) >= b.cfg.CoolDown {
+			b.setState(CircuitHalfOpen, now)
+		}
+	case CircuitClosed:
+		if b.cfg.Window > 0 && now.Sub(b.since) >= b.cfg.Window {
+			b.since = now
+			b.calls, b.failures = 0, 0
+		}
+	}
+}
+
+// setState changes the state of the circuit and resets its counts. It must be called with `mu` held.
+func (b *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.since = now
+	b.calls, b.failures, b.inFlight, b.successes = 0, 0, 0, 0
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, state)
+	}
+}
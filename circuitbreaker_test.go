@@ -0,0 +1,257 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CircuitBreakerSuite struct {
+	suite.Suite
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s := new(CircuitBreakerSuite)
+	suite.Run(t, s)
+}
+
+// stateRecorder records the state changes of a circuit breaker
+type stateRecorder struct {
+	mu      sync.Mutex
+	changes []CircuitState
+}
+
+func (r *stateRecorder) OnStateChange(_, to CircuitState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, to)
+}
+
+func (r *stateRecorder) Changes() []CircuitState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.changes
+}
+
+func succeed(context.Context) error { return nil }
+
+func fail(context.Context) error { return io.EOF }
+
+func (s *CircuitBreakerSuite) TestOpenAndClose() {
+	ctx := context.Background()
+	recorder := &stateRecorder{}
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio:  0.5,
+		MinCalls:      4,
+		CoolDown:      20 * time.Millisecond,
+		HalfOpenCalls: 2,
+		OnStateChange: recorder.OnStateChange,
+	})
+	s.Equal(CircuitClosed, breaker.State())
+
+	// The ratio is not evaluated until the minimum number of calls is reached
+	s.NoError(breaker.Do(ctx, succeed))
+	s.NoError(breaker.Do(ctx, succeed))
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	s.Equal(CircuitClosed, breaker.State())
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	s.Equal(CircuitOpen, breaker.State())
+
+	// Calls are rejected while open
+	called := false
+	err := breaker.Do(ctx, func(context.Context) error {
+		called = true
+		return nil
+	})
+	s.Equal(ErrCircuitOpen, err)
+	s.False(called)
+
+	// After the cool-down, the trial calls close the circuit once they all succeed
+	time.Sleep(30 * time.Millisecond)
+	s.Equal(CircuitHalfOpen, breaker.State())
+	s.NoError(breaker.Do(ctx, succeed))
+	s.Equal(CircuitHalfOpen, breaker.State())
+	s.NoError(breaker.Do(ctx, succeed))
+	s.Equal(CircuitClosed, breaker.State())
+
+	s.Equal([]CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}, recorder.Changes())
+}
+
+func (s *CircuitBreakerSuite) TestHalfOpenFailure() {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1, CoolDown: 10 * time.Millisecond})
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	s.Equal(CircuitOpen, breaker.State())
+
+	time.Sleep(20 * time.Millisecond)
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	s.Equal(CircuitOpen, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestHalfOpenLimitsTrialCalls() {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1})
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+
+	// The cool-down of zero lets a single trial call through
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- breaker.Do(ctx, func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	s.Equal(ErrCircuitOpen, breaker.Do(ctx, succeed))
+	close(release)
+	s.NoError(<-done)
+	s.Equal(CircuitClosed, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestWindow() {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 0.5, MinCalls: 2, Window: 20 * time.Millisecond})
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+
+	// The failure from the previous window is not counted
+	time.Sleep(30 * time.Millisecond)
+	s.NoError(breaker.Do(ctx, succeed))
+	s.NoError(breaker.Do(ctx, succeed))
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	s.Equal(CircuitClosed, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestIsFailure() {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio: 1,
+		IsFailure: func(err error) bool {
+			return !errors.Is(err, io.EOF)
+		},
+	})
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	s.Equal(CircuitClosed, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestCancelledCallsNotCounted() {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1, CoolDown: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Equal(context.Canceled, breaker.Do(ctx, func(ctx context.Context) error { return ctx.Err() }))
+	s.Equal(CircuitClosed, breaker.State())
+
+	// A cancelled trial call frees its slot
+	breaker = NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1})
+	s.Equal(io.EOF, breaker.Do(context.Background(), fail))
+	s.Equal(context.Canceled, breaker.Do(ctx, func(ctx context.Context) error { return ctx.Err() }))
+	s.Equal(CircuitHalfOpen, breaker.State())
+	s.NoError(breaker.Do(context.Background(), succeed))
+	s.Equal(CircuitClosed, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestStaleCallsIgnored() {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1, CoolDown: time.Hour})
+
+	// A call that started while closed finishes after the circuit opened
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- breaker.Do(ctx, func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	s.Equal(io.EOF, breaker.Do(ctx, fail))
+	close(release)
+	s.NoError(<-done)
+	s.Equal(CircuitOpen, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestPanicCountsAsFailure() {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1, CoolDown: time.Hour})
+	s.Panics(func() {
+		_ = breaker.Do(context.Background(), func(context.Context) error {
+			panic("boom")
+		})
+	})
+	s.Equal(CircuitOpen, breaker.State())
+}
+
+func (s *CircuitBreakerSuite) TestCircuitBreakerJob() {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureRatio: 1, MinCalls: 2, CoolDown: time.Hour})
+	calls := NewSyncMap(map[int]int{})
+	f := func(_ context.Context, v int) error {
+		calls.Set(v, v)
+		return io.EOF
+	}
+	errs := WorkerPoolFromSlice(ctx, generateSeries(5), 1, CircuitBreakerJob(f, breaker))
+	s.Equal([]error{io.EOF, io.EOF, ErrCircuitOpen, ErrCircuitOpen, ErrCircuitOpen}, errs)
+	s.Equal(map[int]int{0: 0, 1: 1}, calls.m)
+
+	out := NewSyncMap(map[string]int{})
+	fkv := func(_ context.Context, k string, v int) error {
+		out.Set(k, v)
+		return nil
+	}
+	errs = WorkerPoolFromMap(ctx, map[string]int{"a": 1}, 1, CircuitBreakerJobKV(fkv, breaker))
+	s.Equal([]error{ErrCircuitOpen}, errs)
+	s.Empty(out.m)
+
+	// A closed circuit calls the job
+	errs = WorkerPoolFromMap(ctx, map[string]int{"a": 1}, 1, CircuitBreakerJobKV(fkv, NewCircuitBreaker(CircuitBreakerConfig{})))
+	s.Empty(errs)
+	s.Equal(map[string]int{"a": 1}, out.m)
+}
+
+func (s *CircuitBreakerSuite) TestWithCircuitBreaker() {
+	ctx := context.Background()
+	recorder := &stateRecorder{}
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureRatio:  1,
+		MinCalls:      3,
+		CoolDown:      time.Hour,
+		OnStateChange: recorder.OnStateChange,
+	})
+	calls := NewSyncMap(map[int]int{})
+	f := func(_ context.Context, v int) error {
+		calls.Set(v, v)
+		return io.EOF
+	}
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		Retryable: func(err error) bool {
+			return !errors.Is(err, ErrCircuitOpen)
+		},
+	}
+	err := WorkerPoolFromSliceE(ctx, generateSeries(3), 1, f, WithCircuitBreaker(breaker), WithRetry(policy))
+
+	// Each attempt is counted, so the circuit opens during the retries of the first item
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal(4, poolErrs.Errors[0].Attempts)
+	s.True(errors.Is(poolErrs.Errors[0], ErrCircuitOpen))
+	s.Equal(1, poolErrs.Errors[1].Attempts)
+	s.True(errors.Is(poolErrs.Errors[1], ErrCircuitOpen))
+	s.Equal(map[int]int{0: 0}, calls.m)
+	s.Equal([]CircuitState{CircuitOpen}, recorder.Changes())
+}
+
+func (s *CircuitBreakerSuite) TestStateString() {
+	s.Equal("closed", CircuitClosed.String())
+	s.Equal("open", CircuitOpen.String())
+	s.Equal("half-open", CircuitHalfOpen.String())
+	s.Equal("unknown", CircuitState(10).String())
+}
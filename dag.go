@@ -0,0 +1,363 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrDependencyCycle is returned when the tasks of a DAG depend on each other in a cycle
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// Task is a function that the DAG executes. It receives the results of the tasks it depends on and returns its own
+// result, which is passed to the tasks that depend on it.
+type Task func(ctx context.Context, inputs TaskInputs) (any, error)
+
+// TaskInputs holds the results of the tasks that a task depends on, by task name
+type TaskInputs map[string]any
+
+// TaskInput returns the result of the task named `name` from `inputs`. It returns false if there is no such result or
+// it is not of type T.
+func TaskInput[T any](inputs TaskInputs, name string) (T, bool) {
+	v, ok := inputs[name].(T)
+	return v, ok
+}
+
+// DAG is a set of named tasks which depend on each other. Running the DAG runs each task once all of the tasks it
+// depends on have succeeded, running independent tasks concurrently. Tasks must not be added while the DAG is running.
+type DAG struct {
+	tasks  []dagTask
+	byName map[string]int
+}
+
+// dagTask is a task of a DAG along with the names of the tasks it depends on
+type dagTask struct {
+	name string
+	f    Task
+	deps []string
+}
+
+// DAGError is returned by DAG.Run when tasks fail
+type DAGError struct {
+	// Errors holds the error of each failed task. The item of each PoolError is the name of the task and its index is
+	// the order in which the task was added.
+	Errors []*PoolError[string]
+	// Skipped holds the names of the tasks that were not run because a task they depend on failed or the DAG was
+	// cancelled, in the order they were added
+	Skipped []string
+}
+
+// Error returns the error messages of all failed tasks
+func (e *DAGError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for ii, err := range e.Errors {
+		messages[ii] = fmt.Sprintf("task %q: %v", err.Item, err.Err)
+	}
+	msg := fmt.Sprintf("%d task(s) failed: %s", len(e.Errors), strings.Join(messages, "; "))
+	if len(e.Skipped) > 0 {
+		msg += fmt.Sprintf(" (%d task(s) skipped)", len(e.Skipped))
+	}
+	return msg
+}
+
+// Unwrap returns the errors of each failed task
+func (e *DAGError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for ii, err := range e.Errors {
+		errs[ii] = err
+	}
+	return errs
+}
+
+// Is reports whether any of the failed tasks has an error that matches `target`
+func (e *DAGError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first failed task with an error that matches `target` and sets `target` to that error value
+func (e *DAGError) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns the names of the failed tasks
+func (e *DAGError) Failed() []string {
+	names := make([]string, len(e.Errors))
+	for ii, err := range e.Errors {
+		names[ii] = err.Item
+	}
+	return names
+}
+
+// NewDAG creates an empty DAG
+func NewDAG() *DAG {
+	return &DAG{byName: make(map[string]int)}
+}
+
+// Add adds a task named `name` which runs after all of the tasks in `deps` have succeeded. The dependencies can be
+// added before or after the task. It returns an error if a task with the same name was already added.
+func (d *DAG) Add(name string, f Task, deps ...string) error {
+	if _, ok := d.byName[name]; ok {
+		return fmt.Errorf("task %q is already added", name)
+	}
+	d.byName[name] = len(d.tasks)
+	d.tasks = append(d.tasks, dagTask{name: name, f: f, deps: deps})
+	return nil
+}
+
+// Validate checks that every dependency is a task of the DAG and that there are no dependency cycles. A cycle is
+// reported with an error that matches ErrDependencyCycle.
+func (d *DAG) Validate() error {
+	remaining := make([]int, len(d.tasks))
+	dependents := make([][]int, len(d.tasks))
+	for ii, t := range d.tasks {
+		for _, dep := range t.deps {
+			jj, ok := d.byName[dep]
+			if !ok {
+				return fmt.Errorf("task %q depends on unknown task %q", t.name, dep)
+			}
+			remaining[ii]++
+			dependents[jj] = append(dependents[jj], ii)
+		}
+	}
+
+	// Remove the tasks without dependencies until only the tasks in or after a cycle remain
+	var ready []int
+	for ii, n := range remaining {
+		if n == 0 {
+			ready = append(ready, ii)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		ii := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, jj := range dependents[ii] {
+			remaining[jj]--
+			if remaining[jj] == 0 {
+				ready = append(ready, jj)
+			}
+		}
+	}
+	if visited == len(d.tasks) {
+		return nil
+	}
+	var names []string
+	for ii, n := range remaining {
+		if n > 0 {
+			names = append(names, d.tasks[ii].name)
+		}
+	}
+	return fmt.Errorf("%w between tasks %s", ErrDependencyCycle, strings.Join(names, ", "))
+}
+
+// Run validates the DAG and runs its tasks with up to `concurrency` tasks running at the same time. Each task
+// receives the results of the tasks it depends on. When a task fails, the tasks that depend on it are skipped while
+// the other tasks continue. Pass WithFailFast() to cancel the running tasks and skip all remaining tasks instead.
+// The options apply to each task as they do to the jobs of a worker pool. A `concurrency` less than 1 is treated as 1.
+//
+// It returns the results of the tasks that succeeded by task name. If any task fails, the error is a *DAGError.
+// If the context is cancelled before any task fails, the context error is returned.
+func (d *DAG) Run(ctx context.Context, concurrency int, opts ...PoolOption) (map[string]any, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	// Without any workers, the scheduler would wait forever for the first task to finish
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Workers report finished tasks back to the scheduler so that it can start the tasks that depend on them
+	finished := make(chan dagResult)
+	stopped := make(chan struct{})
+	report := func(cfg *poolConfig) {
+		cfg.afterJob = func(index int, err error) {
+			select {
+			case finished <- dagResult{index: index, failed: err != nil}:
+			case <-stopped:
+			}
+		}
+	}
+
+	s := newDAGScheduler(d)
+	feed := poolFeeder[string]{
+		feed: func(ctx context.Context, ch chan<- poolJob[string]) []poolJob[string] {
+			defer close(stopped)
+			for s.busy() {
+				out, next := s.next(ch)
+				select {
+				case out <- next:
+					s.dispatched()
+				case r := <-finished:
+					s.finish(r.index, r.failed)
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return nil
+		},
+		// The scheduler is only accessed by the feeder so the tasks that are ready are not reported
+		waiting: func() int {
+			return 0
+		},
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]any, len(d.tasks))
+	work := func(ctx context.Context, j poolJob[string]) error {
+		t := d.tasks[j.index]
+		inputs := make(TaskInputs, len(t.deps))
+		mu.Lock()
+		for _, dep := range t.deps {
+			inputs[dep] = results[dep]
+		}
+		mu.Unlock()
+
+		r, err := t.f(ctx, inputs)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		results[t.name] = r
+		mu.Unlock()
+		return nil
+	}
+
+	errs, _ := runWorkerPool(ctx, concurrency, feed, work, append([]PoolOption{report}, opts...))
+	skipped := s.skipped()
+	if len(errs) == 0 {
+		if len(skipped) > 0 {
+			return results, ctx.Err()
+		}
+		return results, nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Index < errs[j].Index
+	})
+	return results, &DAGError{Errors: errs, Skipped: skipped}
+}
+
+// dagResult is sent by the workers to the scheduler when a task is finished
+type dagResult struct {
+	index  int
+	failed bool
+}
+
+// dagTaskState is the progress of a task of a DAG while it is running
+type dagTaskState int
+
+const (
+	dagPending dagTaskState = iota
+	dagReady
+	dagRunning
+	dagSucceeded
+	dagFailed
+	dagSkipped
+)
+
+// dagScheduler decides which tasks of a DAG are ready to run
+type dagScheduler struct {
+	d          *DAG
+	state      []dagTaskState
+	remaining  []int
+	dependents [][]int
+	ready      []int
+	running    int
+}
+
+// newDAGScheduler creates a dagScheduler with the tasks that have no dependencies ready to run. The DAG must be valid.
+func newDAGScheduler(d *DAG) *dagScheduler {
+	s := &dagScheduler{
+		d:          d,
+		state:      make([]dagTaskState, len(d.tasks)),
+		remaining:  make([]int, len(d.tasks)),
+		dependents: make([][]int, len(d.tasks)),
+	}
+	for ii, t := range d.tasks {
+		s.remaining[ii] = len(t.deps)
+		for _, dep := range t.deps {
+			jj := d.byName[dep]
+			s.dependents[jj] = append(s.dependents[jj], ii)
+		}
+		if len(t.deps) == 0 {
+			s.state[ii] = dagReady
+			s.ready = append(s.ready, ii)
+		}
+	}
+	return s
+}
+
+// next returns the channel and job to send next. The channel is nil if no task is ready, which disables the send in
+// a select statement.
+func (s *dagScheduler) next(ch chan<- poolJob[string]) (chan<- poolJob[string], poolJob[string]) {
+	if len(s.ready) == 0 {
+		return nil, poolJob[string]{}
+	}
+	ii := s.ready[0]
+	return ch, poolJob[string]{index: ii, item: s.d.tasks[ii].name}
+}
+
+// dispatched marks the next ready task as running
+func (s *dagScheduler) dispatched() {
+	s.state[s.ready[0]] = dagRunning
+	s.ready = s.ready[1:]
+	s.running++
+}
+
+// finish marks the task with the given index as finished. The tasks that depend on it are made ready once all of
+// their dependencies succeeded, or skipped if it failed.
+func (s *dagScheduler) finish(index int, failed bool) {
+	s.running--
+	if failed {
+		s.state[index] = dagFailed
+		s.skip(index)
+		return
+	}
+	s.state[index] = dagSucceeded
+	for _, jj := range s.dependents[index] {
+		s.remaining[jj]--
+		if s.remaining[jj] == 0 && s.state[jj] == dagPending {
+			s.state[jj] = dagReady
+			s.ready = append(s.ready, jj)
+		}
+	}
+}
+
+// skip marks all tasks that depend on the task with the given index, directly or indirectly, as skipped
+func (s *dagScheduler) skip(index int) {
+	for _, jj := range s.dependents[index] {
+		if s.state[jj] == dagPending {
+			s.state[jj] = dagSkipped
+			s.skip(jj)
+		}
+	}
+}
+
+// busy reports whether any tasks are ready or running. Once neither is true, the remaining tasks can never run.
+func (s *dagScheduler) busy() bool {
+	return len(s.ready) > 0 || s.running > 0
+}
+
+// skipped returns the names of the tasks that did not run, in the order they were added
+func (s *dagScheduler) skipped() []string {
+	var names []string
+	for ii, state := range s.state {
+		if state == dagPending || state == dagReady || state == dagSkipped {
+			names = append(names, s.d.tasks[ii].name)
+		}
+	}
+	return names
+}
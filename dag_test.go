@@ -0,0 +1,245 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DAGSuite struct {
+	suite.Suite
+}
+
+func TestDAG(t *testing.T) {
+	s := new(DAGSuite)
+	suite.Run(t, s)
+}
+
+// taskLog records the order in which tasks are run
+type taskLog struct {
+	mu    sync.Mutex
+	order []string
+}
+
+// task returns a task which records its name and returns the concatenation of its inputs and its name
+func (l *taskLog) task(name string) Task {
+	return func(_ context.Context, inputs TaskInputs) (any, error) {
+		l.mu.Lock()
+		l.order = append(l.order, name)
+		l.mu.Unlock()
+
+		var parts []string
+		for _, v := range inputs {
+			parts = append(parts, v.(string))
+		}
+		return strings.Join(append(parts, name), ">"), nil
+	}
+}
+
+// before reports whether task `a` ran before task `b`
+func (l *taskLog) before(a, b string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ia, ib := -1, -1
+	for ii, name := range l.order {
+		switch name {
+		case a:
+			ia = ii
+		case b:
+			ib = ii
+		}
+	}
+	return ia >= 0 && ib >= 0 && ia < ib
+}
+
+func failingTask(err error) Task {
+	return func(context.Context, TaskInputs) (any, error) {
+		return nil, err
+	}
+}
+
+func (s *DAGSuite) TestRun() {
+	ctx := context.Background()
+	log := &taskLog{}
+	d := NewDAG()
+	// The tasks can be added before their dependencies
+	s.NoError(d.Add("index", log.task("index"), "parse"))
+	s.NoError(d.Add("parse", log.task("parse"), "download"))
+	s.NoError(d.Add("download", log.task("download")))
+	s.NoError(d.Add("thumbnail", log.task("thumbnail"), "download"))
+
+	results, err := d.Run(ctx, 2)
+	s.NoError(err)
+	s.Equal(map[string]any{
+		"download":  "download",
+		"parse":     "download>parse",
+		"index":     "download>parse>index",
+		"thumbnail": "download>thumbnail",
+	}, results)
+	s.True(log.before("download", "parse"))
+	s.True(log.before("parse", "index"))
+	s.True(log.before("download", "thumbnail"))
+}
+
+func (s *DAGSuite) TestConcurrency() {
+	ctx := context.Background()
+	var mu sync.Mutex
+	var running, maxRunning int
+	task := func(context.Context, TaskInputs) (any, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil, nil
+	}
+
+	d := NewDAG()
+	for ii := 0; ii < 6; ii++ {
+		s.NoError(d.Add(fmt.Sprint(ii), task))
+	}
+	_, err := d.Run(ctx, 3)
+	s.NoError(err)
+	s.Equal(3, maxRunning)
+
+	s.Run("concurrency less than one", func() {
+		maxRunning = 0
+		_, err := d.Run(ctx, 0)
+		s.NoError(err)
+		s.Equal(1, maxRunning)
+	})
+}
+
+func (s *DAGSuite) TestFailureSkipsDependents() {
+	ctx := context.Background()
+	log := &taskLog{}
+	d := NewDAG()
+	s.NoError(d.Add("download", log.task("download")))
+	s.NoError(d.Add("parse", failingTask(io.EOF), "download"))
+	s.NoError(d.Add("index", log.task("index"), "parse"))
+	s.NoError(d.Add("report", log.task("report"), "index", "thumbnail"))
+	s.NoError(d.Add("thumbnail", log.task("thumbnail"), "download"))
+
+	results, err := d.Run(ctx, 1)
+	s.Equal(map[string]any{"download": "download", "thumbnail": "download>thumbnail"}, results)
+
+	var dagErr *DAGError
+	s.Require().True(errors.As(err, &dagErr))
+	s.Equal([]string{"parse"}, dagErr.Failed())
+	s.Equal([]string{"index", "report"}, dagErr.Skipped)
+	s.Equal(1, dagErr.Errors[0].Index)
+	s.Equal(`1 task(s) failed: task "parse": EOF (2 task(s) skipped)`, err.Error())
+	s.True(errors.Is(err, io.EOF))
+	s.False(errors.Is(err, io.ErrUnexpectedEOF))
+	s.Len(dagErr.Unwrap(), 1)
+}
+
+func (s *DAGSuite) TestFailFast() {
+	ctx := context.Background()
+	started := make(chan struct{})
+	d := NewDAG()
+	s.NoError(d.Add("slow", func(ctx context.Context, _ TaskInputs) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	s.NoError(d.Add("fail", func(context.Context, TaskInputs) (any, error) {
+		<-started
+		return nil, io.EOF
+	}))
+	s.NoError(d.Add("after", failingTask(nil), "slow"))
+
+	_, err := d.Run(ctx, 2, WithFailFast())
+	var dagErr *DAGError
+	s.Require().True(errors.As(err, &dagErr))
+	s.ElementsMatch([]string{"slow", "fail"}, dagErr.Failed())
+	s.Equal([]string{"after"}, dagErr.Skipped)
+	s.True(errors.Is(err, context.Canceled))
+}
+
+func (s *DAGSuite) TestPanic() {
+	ctx := context.Background()
+	d := NewDAG()
+	s.NoError(d.Add("ok", failingTask(nil)))
+	s.NoError(d.Add("panic", func(context.Context, TaskInputs) (any, error) {
+		panic("boom")
+	}))
+
+	_, err := d.Run(ctx, 2)
+	var panicErr *PanicError
+	s.Require().True(errors.As(err, &panicErr))
+	s.Equal("boom", panicErr.Value)
+	var retryErr *RetryError
+	s.False(errors.As(err, &retryErr))
+}
+
+func (s *DAGSuite) TestCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDAG()
+	s.NoError(d.Add("first", func(context.Context, TaskInputs) (any, error) {
+		cancel()
+		return 1, nil
+	}))
+	s.NoError(d.Add("second", failingTask(nil), "first"))
+
+	results, err := d.Run(ctx, 1)
+	s.Equal(context.Canceled, err)
+	s.Equal(map[string]any{"first": 1}, results)
+}
+
+func (s *DAGSuite) TestRetry() {
+	ctx := context.Background()
+	attempts := 0
+	d := NewDAG()
+	s.NoError(d.Add("flaky", func(context.Context, TaskInputs) (any, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, io.EOF
+		}
+		return "ok", nil
+	}))
+	s.NoError(d.Add("next", func(_ context.Context, inputs TaskInputs) (any, error) {
+		v, ok := TaskInput[string](inputs, "flaky")
+		s.True(ok)
+		_, ok = TaskInput[int](inputs, "flaky")
+		s.False(ok)
+		return v + "!", nil
+	}, "flaky"))
+
+	results, err := d.Run(ctx, 1, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	s.NoError(err)
+	s.Equal("ok!", results["next"])
+}
+
+func (s *DAGSuite) TestValidate() {
+	ctx := context.Background()
+	d := NewDAG()
+	s.NoError(d.Add("a", failingTask(nil), "c"))
+	s.NoError(d.Add("b", failingTask(nil), "a"))
+	s.NoError(d.Add("c", failingTask(nil), "b"))
+	s.NoError(d.Add("d", failingTask(nil)))
+
+	err := d.Validate()
+	s.True(errors.Is(err, ErrDependencyCycle))
+	s.Equal("dependency cycle between tasks a, b, c", err.Error())
+
+	_, err = d.Run(ctx, 1)
+	s.True(errors.Is(err, ErrDependencyCycle))
+
+	d = NewDAG()
+	s.NoError(d.Add("a", failingTask(nil), "missing"))
+	s.Equal(`task "a" depends on unknown task "missing"`, d.Validate().Error())
+	s.Equal(`task "a" is already added`, d.Add("a", failingTask(nil)).Error())
+}
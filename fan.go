@@ -1,6 +1,9 @@
 package simpleflow
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // FanOut reads from the `from` channel and publishes the data across all `to` channels
 func FanOut[T any](from <-chan T, to ...chan<- T) {
@@ -41,3 +44,81 @@ func FanInAndClose[T any](to chan<- T, from ...<-chan T) {
 	FanIn(to, from...)
 	close(to)
 }
+
+// FanOutCtx reads from the `from` channel and publishes the data across all `to` channels until `from` is closed or
+// the context is cancelled. It returns the number of values that were published to every `to` channel, along with
+// the context error if the context was cancelled before `from` was closed.
+func FanOutCtx[T any](ctx context.Context, from <-chan T, to ...chan<- T) (int, error) {
+	var n int
+	for {
+		v, ok, err := recvCtx(ctx, from)
+		if err != nil || !ok {
+			return n, err
+		}
+		for _, ch := range to {
+			if !sendCtx(ctx, ch, v) {
+				return n, ctx.Err()
+			}
+		}
+		n++
+	}
+}
+
+// FanOutAndCloseCtx is like FanOutCtx but closes each `to` channel once it returns, including when the context is
+// cancelled
+func FanOutAndCloseCtx[T any](ctx context.Context, from <-chan T, to ...chan<- T) (int, error) {
+	defer CloseManyWriters(to...)
+	return FanOutCtx(ctx, from, to...)
+}
+
+// FanInCtx reads from each `from` channel and writes to the `to` channel until all `from` channels are closed or the
+// context is cancelled. It returns the number of values that were written to `to`, along with the context error if
+// the context was cancelled before all `from` channels were closed. All go routines started by FanInCtx have exited
+// by the time it returns.
+func FanInCtx[T any](ctx context.Context, to chan<- T, from ...<-chan T) (int, error) {
+	// Each go routine records its own count and error so that they can be combined once all of them exit
+	counts := make([]int, len(from))
+	errs := make([]error, len(from))
+	var wg sync.WaitGroup
+	wg.Add(len(from))
+	for ii, ch := range from {
+		go func(ii int, ch <-chan T) {
+			defer wg.Done()
+			counts[ii], errs[ii] = forwardCtx(ctx, to, ch)
+		}(ii, ch)
+	}
+	wg.Wait()
+
+	var n int
+	var err error
+	for ii := range from {
+		n += counts[ii]
+		if errs[ii] != nil {
+			err = errs[ii]
+		}
+	}
+	return n, err
+}
+
+// FanInAndCloseCtx is like FanInCtx but closes the `to` channel once it returns, including when the context is
+// cancelled
+func FanInAndCloseCtx[T any](ctx context.Context, to chan<- T, from ...<-chan T) (int, error) {
+	defer close(to)
+	return FanInCtx(ctx, to, from...)
+}
+
+// forwardCtx writes the values read from the `from` channel to the `to` channel until `from` is closed or the context
+// is cancelled. It returns the number of values written.
+func forwardCtx[T any](ctx context.Context, to chan<- T, from <-chan T) (int, error) {
+	var n int
+	for {
+		v, ok, err := recvCtx(ctx, from)
+		if err != nil || !ok {
+			return n, err
+		}
+		if !sendCtx(ctx, to, v) {
+			return n, ctx.Err()
+		}
+		n++
+	}
+}
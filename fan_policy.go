@@ -0,0 +1,229 @@
+package simpleflow
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deliveryMode is the way a DeliveryPolicy handles a subscriber that is not ready to receive a value
+type deliveryMode int
+
+const (
+	deliverBlock deliveryMode = iota
+	deliverDropNewest
+	deliverDropOldest
+	deliverDisconnect
+)
+
+// DeliveryPolicy determines what happens when a subscriber is not ready to receive a value. Use BlockOnFull,
+// DropNewest, DropOldest or DisconnectAfter to create one. The zero value is BlockOnFull.
+type DeliveryPolicy struct {
+	mode    deliveryMode
+	size    int
+	timeout time.Duration
+}
+
+// BlockOnFull returns a DeliveryPolicy which waits until the subscriber receives each value, which holds back the
+// values for all other subscribers in the meantime
+func BlockOnFull() DeliveryPolicy {
+	return DeliveryPolicy{mode: deliverBlock}
+}
+
+// DropNewest returns a DeliveryPolicy which drops a value if the subscriber is not ready to receive it or its channel
+// buffer is full
+func DropNewest() DeliveryPolicy {
+	return DeliveryPolicy{mode: deliverDropNewest}
+}
+
+// DropOldest returns a DeliveryPolicy which holds up to `size` values that the subscriber is not ready to receive in a
+// ring buffer. When the ring buffer is full, its oldest value is dropped to make room for the new value. A `size` less
+// than 1 is treated as 1.
+func DropOldest(size int) DeliveryPolicy {
+	if size < 1 {
+		size = 1
+	}
+	return DeliveryPolicy{mode: deliverDropOldest, size: size}
+}
+
+// DisconnectAfter returns a DeliveryPolicy which waits up to `timeout` for the subscriber to receive each value. If the
+// subscriber does not receive the value in time, the value is dropped and the subscriber is disconnected by closing its
+// channel. No more values are sent to a disconnected subscriber.
+func DisconnectAfter(timeout time.Duration) DeliveryPolicy {
+	return DeliveryPolicy{mode: deliverDisconnect, timeout: timeout}
+}
+
+// Subscriber is a channel that receives values from FanOutWithPolicy, along with the policy that determines what
+// happens when it is not ready to receive a value. A Subscriber can only be used by one fan-out.
+type Subscriber[T any] struct {
+	ch     chan<- T
+	policy DeliveryPolicy
+
+	dropped      int64
+	disconnected int32
+
+	// The fields below hold the ring buffer of a DropOldest policy. `ready` is signalled when a value is added to
+	// `buffered` and `finished` is set once no more values will be added.
+	mu       sync.Mutex
+	buffered []T
+	finished bool
+	ready    chan struct{}
+}
+
+// NewSubscriber creates a Subscriber which sends values to `ch` according to `policy`
+func NewSubscriber[T any](ch chan<- T, policy DeliveryPolicy) *Subscriber[T] {
+	return &Subscriber[T]{ch: ch, policy: policy, ready: make(chan struct{}, 1)}
+}
+
+// Dropped returns the number of values that were dropped for the subscriber. The values that are not sent to a
+// disconnected subscriber are not counted.
+func (s *Subscriber[T]) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Disconnected returns whether the subscriber was disconnected for not receiving a value in time
+func (s *Subscriber[T]) Disconnected() bool {
+	return atomic.LoadInt32(&s.disconnected) == 1
+}
+
+// FanOutWithPolicy reads from the `from` channel and publishes the data to all subscribers until `from` is closed or
+// the context is cancelled. The policy of each subscriber determines what happens when it is not ready to receive a
+// value, so that a slow subscriber does not need to hold back the others. The channel of each subscriber is closed
+// once all values are delivered to it, it is disconnected or the context is cancelled.
+//
+// It returns the number of values that were read from `from`, along with the context error if the context was
+// cancelled before `from` was closed or before the values held in the ring buffers of the subscribers were delivered.
+func FanOutWithPolicy[T any](ctx context.Context, from <-chan T, subscribers ...*Subscriber[T]) (n int, err error) {
+	// Subscribers with a ring buffer receive their values from a separate go routine so that they can fall behind
+	var wg sync.WaitGroup
+	drained := make([]bool, len(subscribers))
+	for ii, sub := range subscribers {
+		if sub.policy.mode == deliverDropOldest {
+			wg.Add(1)
+			go func(ii int, sub *Subscriber[T]) {
+				defer wg.Done()
+				drained[ii] = sub.forward(ctx)
+			}(ii, sub)
+		} else {
+			drained[ii] = true
+		}
+	}
+	defer func() {
+		for _, sub := range subscribers {
+			sub.finish()
+		}
+		wg.Wait()
+		for ii, sub := range subscribers {
+			if !drained[ii] && err == nil {
+				err = ctx.Err()
+			}
+			if !sub.Disconnected() {
+				close(sub.ch)
+			}
+		}
+	}()
+
+	for {
+		v, ok, err := recvCtx(ctx, from)
+		if err != nil || !ok {
+			return n, err
+		}
+		for _, sub := range subscribers {
+			if !sub.deliver(ctx, v) {
+				return n, ctx.Err()
+			}
+		}
+		n++
+	}
+}
+
+// deliver sends the value to the subscriber according to its policy. It returns false if the context was cancelled
+// before the value was handled.
+func (s *Subscriber[T]) deliver(ctx context.Context, v T) bool {
+	switch s.policy.mode {
+	case deliverDropNewest:
+		select {
+		case s.ch <- v:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	case deliverDropOldest:
+		s.mu.Lock()
+		if len(s.buffered) == s.policy.size {
+			s.buffered = s.buffered[1:]
+			atomic.AddInt64(&s.dropped, 1)
+		}
+		s.buffered = append(s.buffered, v)
+		s.mu.Unlock()
+		s.signal()
+	case deliverDisconnect:
+		if s.Disconnected() {
+			return true
+		}
+		// Try without a timer first so that a subscriber that is ready is never disconnected by a short timeout
+		select {
+		case s.ch <- v:
+			return true
+		default:
+		}
+		timer := time.NewTimer(s.policy.timeout)
+		defer timer.Stop()
+		select {
+		case s.ch <- v:
+		case <-timer.C:
+			atomic.AddInt64(&s.dropped, 1)
+			atomic.StoreInt32(&s.disconnected, 1)
+			close(s.ch)
+		case <-ctx.Done():
+			return false
+		}
+	default:
+		return sendCtx(ctx, s.ch, v)
+	}
+	return true
+}
+
+// forward sends the values in the ring buffer to the subscriber until the buffer is empty after finish is called, or
+// the context is cancelled. It returns false if the context was cancelled before the buffer was drained.
+func (s *Subscriber[T]) forward(ctx context.Context) bool {
+	for {
+		s.mu.Lock()
+		if len(s.buffered) == 0 {
+			finished := s.finished
+			s.mu.Unlock()
+			if finished {
+				return true
+			}
+			select {
+			case <-s.ready:
+				continue
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// The value being sent is taken out of the buffer so that it is not dropped while it is sent
+		v := s.buffered[0]
+		s.buffered = s.buffered[1:]
+		s.mu.Unlock()
+		if !sendCtx(ctx, s.ch, v) {
+			return false
+		}
+	}
+}
+
+// finish marks that no more values will be added to the ring buffer
+func (s *Subscriber[T]) finish() {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+	s.signal()
+}
+
+// signal wakes up the go routine sending the values in the ring buffer
+func (s *Subscriber[T]) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
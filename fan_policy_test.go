@@ -0,0 +1,159 @@
+package simpleflow
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FanPolicySuite struct {
+	suite.Suite
+}
+
+func TestFanPolicy(t *testing.T) {
+	s := new(FanPolicySuite)
+	suite.Run(t, s)
+}
+
+func (s *FanPolicySuite) TestBlockOnFull() {
+	ctx := context.Background()
+	N := 5
+	sink1 := make(chan int, N)
+	sink2 := make(chan int, N)
+	sub1 := NewSubscriber(sink1, BlockOnFull())
+	sub2 := NewSubscriber(sink2, DeliveryPolicy{})
+
+	n, err := FanOutWithPolicy(ctx, closedChan(generateSeries(N)...), sub1, sub2)
+	s.NoError(err)
+	s.Equal(N, n)
+
+	// The subscriber channels are closed once all values are delivered
+	s.Equal(generateSeries(N), ChannelToSlice(sink1))
+	s.Equal(generateSeries(N), ChannelToSlice(sink2))
+	s.Zero(sub1.Dropped())
+	s.Zero(sub2.Dropped())
+}
+
+func (s *FanPolicySuite) TestDropNewest() {
+	ctx := context.Background()
+	N := 5
+	fast := make(chan int, N)
+	// The slow subscriber has room for two values and is not read from until the fan-out returns
+	slow := make(chan int, 2)
+	fastSub := NewSubscriber(fast, BlockOnFull())
+	slowSub := NewSubscriber(slow, DropNewest())
+
+	n, err := FanOutWithPolicy(ctx, closedChan(generateSeries(N)...), fastSub, slowSub)
+	s.NoError(err)
+	s.Equal(N, n)
+
+	s.Equal(generateSeries(N), ChannelToSlice(fast))
+	s.Equal([]int{0, 1}, ChannelToSlice(slow))
+	s.EqualValues(3, slowSub.Dropped())
+	s.False(slowSub.Disconnected())
+}
+
+func (s *FanPolicySuite) TestDropOldest() {
+	ctx := context.Background()
+	N := 10
+	fast := make(chan int, N)
+	slow := make(chan int)
+	fastSub := NewSubscriber(fast, BlockOnFull())
+	slowSub := NewSubscriber(slow, DropOldest(3))
+
+	done := make(chan int, 1)
+	go func() {
+		n, err := FanOutWithPolicy(ctx, closedChan(generateSeries(N)...), fastSub, slowSub)
+		s.NoError(err)
+		done <- n
+	}()
+
+	// Only start reading from the slow subscriber once all values are fanned out
+	for len(fast) < N {
+		runtime.Gosched()
+	}
+	received := ChannelToSlice(slow)
+	s.Equal(N, <-done)
+
+	// The slow subscriber may receive the first value before it falls behind, but it always receives the last values
+	s.Equal(generateSeries(N), ChannelToSlice(fast))
+	s.Equal([]int{7, 8, 9}, received[len(received)-3:])
+	s.EqualValues(N-len(received), slowSub.Dropped())
+
+	s.Run("size less than one", func() {
+		s.Equal(DropOldest(1), DropOldest(0))
+	})
+}
+
+func (s *FanPolicySuite) TestDisconnectAfter() {
+	ctx := context.Background()
+	N := 5
+	fast := make(chan int, N)
+	slow := make(chan int, 1)
+	fastSub := NewSubscriber(fast, BlockOnFull())
+	slowSub := NewSubscriber(slow, DisconnectAfter(10*time.Millisecond))
+
+	n, err := FanOutWithPolicy(ctx, closedChan(generateSeries(N)...), fastSub, slowSub)
+	s.NoError(err)
+	s.Equal(N, n)
+
+	// The slow subscriber is disconnected when its buffer is full and the remaining values are not counted
+	s.Equal(generateSeries(N), ChannelToSlice(fast))
+	s.Equal([]int{0}, ChannelToSlice(slow))
+	s.True(slowSub.Disconnected())
+	s.EqualValues(1, slowSub.Dropped())
+}
+
+func (s *FanPolicySuite) TestCancelled() {
+	s.Run("blocked subscriber", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		source := make(chan int, 2)
+		LoadChannel(source, 1, 2)
+		sink := make(chan int, 1)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		n, err := FanOutWithPolicy(ctx, source, NewSubscriber(sink, BlockOnFull()))
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(1, n)
+		s.Equal([]int{1}, ChannelToSlice(sink))
+	})
+
+	s.Run("subscriber waiting to be disconnected", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		sink := make(chan int)
+		sub := NewSubscriber(sink, DisconnectAfter(time.Hour))
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		n, err := FanOutWithPolicy(ctx, closedChan(1), sub)
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(0, n)
+		s.False(sub.Disconnected())
+		s.Empty(ChannelToSlice(sink))
+	})
+
+	s.Run("ring buffer not drained", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		sink := make(chan int)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		// The source is drained into the ring buffer but the subscriber never reads it
+		n, err := FanOutWithPolicy(ctx, closedChan(1, 2, 3), NewSubscriber(sink, DropOldest(5)))
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(3, n)
+		s.Empty(ChannelToSlice(sink))
+	})
+
+	s.Run("ring buffer waiting for values", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		sink := make(chan int)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		n, err := FanOutWithPolicy(ctx, make(chan int), NewSubscriber(sink, DropOldest(5)))
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(0, n)
+		s.Empty(ChannelToSlice(sink))
+	})
+}
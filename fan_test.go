@@ -1,7 +1,9 @@
 package simpleflow
 
 import (
+	"context"
 	"github.com/stretchr/testify/suite"
+	"runtime"
 	"testing"
 )
 
@@ -44,3 +46,66 @@ func (s *FanSuite) TestFanOutAndIn() {
 
 	s.ElementsMatch(faninResults, append(generateSeries(N), generateSeries(N)...))
 }
+
+func (s *FanSuite) TestFanOutAndInCtx() {
+	ctx := context.Background()
+	N := 5
+
+	source := make(chan int, N)
+	LoadChannel(source, generateSeries(N)...)
+	close(source)
+
+	fanoutSink1 := make(chan int, N)
+	fanoutSink2 := make(chan int, N)
+	n, err := FanOutAndCloseCtx(ctx, source, fanoutSink1, fanoutSink2)
+	s.NoError(err)
+	s.Equal(N, n)
+
+	fanInSink := make(chan int, 2*N)
+	n, err = FanInAndCloseCtx(ctx, fanInSink, fanoutSink1, fanoutSink2)
+	s.NoError(err)
+	s.Equal(2*N, n)
+	s.ElementsMatch(ChannelToSlice(fanInSink), append(generateSeries(N), generateSeries(N)...))
+}
+
+func (s *FanSuite) TestFanOutCtxCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan int, 3)
+	LoadChannel(source, 1, 2, 3)
+
+	// The second sink has no buffer and no reader, so the first value is never published to every sink
+	fanoutSink1 := make(chan int, 3)
+	fanoutSink2 := make(chan int)
+	go func() {
+		for len(fanoutSink1) == 0 {
+			runtime.Gosched()
+		}
+		cancel()
+	}()
+	n, err := FanOutAndCloseCtx(ctx, source, fanoutSink1, fanoutSink2)
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(0, n)
+	s.Equal([]int{1}, ChannelToSlice(fanoutSink1))
+	s.Empty(ChannelToSlice(fanoutSink2))
+}
+
+func (s *FanSuite) TestFanInCtxCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The sources are never closed and the sink only has space for some of the values
+	source1 := make(chan int, 3)
+	LoadChannel(source1, 1, 2, 3)
+	source2 := make(chan int, 3)
+	LoadChannel(source2, 4, 5, 6)
+	fanInSink := make(chan int, 4)
+	go func() {
+		for len(fanInSink) < cap(fanInSink) {
+			runtime.Gosched()
+		}
+		cancel()
+	}()
+	n, err := FanInAndCloseCtx(ctx, fanInSink, source1, source2)
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(4, n)
+	s.Len(ChannelToSlice(fanInSink), 4)
+}
@@ -0,0 +1,360 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrPipelineStarted is returned when running a Pipeline that has already been run
+var ErrPipelineStarted = errors.New("pipeline has already been run")
+
+// StageError is returned for a stage of a Pipeline that failed
+type StageError struct {
+	// Stage is the name of the stage
+	Stage string
+	// Err is the error returned by the function of the stage
+	Err error
+}
+
+// Error returns the error along with the name of the stage
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %q: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the error returned by the function of the stage
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// StageOptions configures a stage of a Pipeline
+type StageOptions struct {
+	// Name identifies the stage in errors. If empty, the stage is named after its position in the pipeline.
+	Name string
+	// Workers is the number of goroutines that call the function of the stage. Values less than 1 are treated as 1.
+	// With more than one worker, the order of the items is not preserved.
+	Workers int
+	// Buffer is the size of the buffer of the output channel of the stage
+	Buffer int
+}
+
+// Pipeline is a chain of stages connected by channels. Stages are added with the *Source and *Stage functions, whose
+// type parameters ensure that the output of each stage matches the input of the next. Each stage runs concurrently
+// and the channels between them are created and closed by the pipeline. Every output of a stage must be consumed by
+// another stage.
+type Pipeline struct {
+	mu      sync.Mutex
+	stages  []pipelineStage
+	streams []pipelineStream
+	started bool
+}
+
+// pipelineStage is a stage of a Pipeline. `run` blocks until the stage is finished and passes its errors to `fail`.
+type pipelineStage struct {
+	name string
+	run  func(ctx context.Context, fail func(error))
+}
+
+// pipelineStream is the output of a stage, which must be consumed by another stage before the pipeline is run
+type pipelineStream interface {
+	producer() string
+	isConsumed() bool
+}
+
+// Stream is the output of a stage of a Pipeline. It is consumed by passing it to the next stage.
+type Stream[T any] struct {
+	p        *Pipeline
+	name     string
+	ch       chan T
+	consumed bool
+}
+
+// NewPipeline creates an empty Pipeline
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Run starts all stages of the pipeline and blocks until they finish. If a stage fails or panics, the pipeline is
+// cancelled and the remaining items are abandoned. It returns a *StageError for each stage that failed, or the context
+// error if the context is cancelled. A pipeline can only be run once.
+func (p *Pipeline) Run(ctx context.Context) []error {
+	p.mu.Lock()
+	if p.started {
+		p.mu.Unlock()
+		return []error{ErrPipelineStarted}
+	}
+	p.started = true
+	stages := p.stages
+	for _, s := range p.streams {
+		if !s.isConsumed() {
+			p.mu.Unlock()
+			return []error{fmt.Errorf("the output of stage %q is not consumed", s.producer())}
+		}
+	}
+	p.mu.Unlock()
+
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	wg.Add(len(stages))
+	for _, s := range stages {
+		go func(s pipelineStage) {
+			defer wg.Done()
+			s.run(pipelineCtx, func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				// Stages that fail because the pipeline was cancelled are not reported
+				if pipelineCtx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+					return
+				}
+				errs = append(errs, &StageError{Stage: s.name, Err: err})
+				cancel()
+			})
+		}(s)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 && ctx.Err() != nil {
+		return []error{ctx.Err()}
+	}
+	return errs
+}
+
+// addStage adds a stage to the pipeline. The name defaults to the position of the stage in the pipeline.
+func (p *Pipeline) addStage(name string, run func(ctx context.Context, fail func(error))) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		panic("simpleflow: cannot add a stage to a pipeline that has been run")
+	}
+	if name == "" {
+		name = fmt.Sprintf("stage %d", len(p.stages)+1)
+	}
+	p.stages = append(p.stages, pipelineStage{name: name, run: run})
+	return name
+}
+
+// newStream creates the output of a stage with the given buffer size
+func newStream[T any](p *Pipeline, buffer int) *Stream[T] {
+	s := &Stream[T]{p: p, ch: make(chan T, buffer)}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.streams = append(p.streams, s)
+	return s
+}
+
+// consume marks the stream as consumed by the next stage. It panics if the stream is already consumed since
+// each item can only be received once.
+func (s *Stream[T]) consume() <-chan T {
+	s.p.mu.Lock()
+	defer s.p.mu.Unlock()
+	if s.consumed {
+		panic(fmt.Sprintf("simpleflow: the output of stage %q is consumed more than once, use FanOutStage to "+
+			"send it to several stages", s.name))
+	}
+	s.consumed = true
+	return s.ch
+}
+
+// producer returns the name of the stage that produces the stream
+func (s *Stream[T]) producer() string {
+	return s.name
+}
+
+// isConsumed reports whether the stream is consumed by a stage
+func (s *Stream[T]) isConsumed() bool {
+	return s.consumed
+}
+
+// send sends `v` to the stream, returning false if the context is cancelled first
+func (s *Stream[T]) send(ctx context.Context, v T) bool {
+	select {
+	case s.ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SourceStage adds a stage to the pipeline which calls `f` to produce items. `f` passes each item to `emit`, which
+// blocks until the next stage accepts the item. If `emit` returns an error, the pipeline was cancelled and `f`
+// should return.
+func SourceStage[T any](p *Pipeline, opts StageOptions, f func(ctx context.Context, emit func(T) error) error) *Stream[T] {
+	out := newStream[T](p, opts.Buffer)
+	out.name = p.addStage(opts.Name, func(ctx context.Context, fail func(error)) {
+		defer close(out.ch)
+		emit := func(v T) error {
+			if !out.send(ctx, v) {
+				return ctx.Err()
+			}
+			return nil
+		}
+		if err := callStage(nil, func() error { return f(ctx, emit) }); err != nil {
+			fail(err)
+		}
+	})
+	return out
+}
+
+// SliceSource adds a stage to the pipeline which produces each element of `items` in order
+func SliceSource[T any](p *Pipeline, items []T) *Stream[T] {
+	return SourceStage(p, StageOptions{Name: "source"}, func(ctx context.Context, emit func(T) error) error {
+		for _, v := range items {
+			if emit(v) != nil {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// ChanSource adds a stage to the pipeline which produces the values read from `items` until it is closed
+func ChanSource[T any](p *Pipeline, items <-chan T) *Stream[T] {
+	return SourceStage(p, StageOptions{Name: "source"}, func(ctx context.Context, emit func(T) error) error {
+		for {
+			select {
+			case v, ok := <-items:
+				if !ok || emit(v) != nil {
+					return nil
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+}
+
+// MapStage adds a stage to the pipeline which calls `f` for each item of `in` and produces its results
+func MapStage[T, R any](in *Stream[T], opts StageOptions, f func(ctx context.Context, item T) (R, error)) *Stream[R] {
+	out := newStream[R](in.p, opts.Buffer)
+	out.name = addWorkerStage(in, opts, func(ctx context.Context, v T) error {
+		r, err := f(ctx, v)
+		if err != nil {
+			return err
+		}
+		out.send(ctx, r)
+		return nil
+	}, func() { close(out.ch) })
+	return out
+}
+
+// FilterStage adds a stage to the pipeline which produces the items of `in` for which `f` returns true
+func FilterStage[T any](in *Stream[T], opts StageOptions, f func(ctx context.Context, item T) (bool, error)) *Stream[T] {
+	out := newStream[T](in.p, opts.Buffer)
+	out.name = addWorkerStage(in, opts, func(ctx context.Context, v T) error {
+		keep, err := f(ctx, v)
+		if err != nil {
+			return err
+		}
+		if keep {
+			out.send(ctx, v)
+		}
+		return nil
+	}, func() { close(out.ch) })
+	return out
+}
+
+// SinkStage adds a stage to the pipeline which calls `f` for each item of `in`
+func SinkStage[T any](in *Stream[T], opts StageOptions, f Job[T]) {
+	addWorkerStage(in, opts, f, func() {})
+}
+
+// BatchStage adds a stage to the pipeline which groups the items of `in` into batches of up to `size` items. A batch
+// is produced once it is full or once its first item has waited for `linger`. A `linger` of 0 means batches are only
+// produced once they are full or `in` is drained. A `size` less than 1 is treated as 1.
+func BatchStage[T any](in *Stream[T], opts StageOptions, size int, linger time.Duration) *Stream[[]T] {
+	items := in.consume()
+	out := newStream[[]T](in.p, opts.Buffer)
+	out.name = in.p.addStage(opts.Name, func(ctx context.Context, fail func(error)) {
+		defer close(out.ch)
+		_, _ = BatchChanWithLinger(ctx, items, size, linger, nil, out.ch)
+	})
+	return out
+}
+
+// FanOutStage adds a stage to the pipeline which sends each item of `in` to `n` outputs. The stage blocks until every
+// output accepts the item.
+func FanOutStage[T any](in *Stream[T], opts StageOptions, n int) []*Stream[T] {
+	items := in.consume()
+	outs := make([]*Stream[T], n)
+	for ii := range outs {
+		outs[ii] = newStream[T](in.p, opts.Buffer)
+	}
+	name := in.p.addStage(opts.Name, func(ctx context.Context, fail func(error)) {
+		defer func() {
+			for _, out := range outs {
+				close(out.ch)
+			}
+		}()
+		for {
+			select {
+			case v, ok := <-items:
+				if !ok {
+					return
+				}
+				for _, out := range outs {
+					if !out.send(ctx, v) {
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+	for _, out := range outs {
+		out.name = name
+	}
+	return outs
+}
+
+// addWorkerStage adds a stage to the pipeline which calls `f` for each item of `in` from `opts.Workers` goroutines.
+// `done` is called once all workers have exited.
+func addWorkerStage[T any](in *Stream[T], opts StageOptions, f func(context.Context, T) error, done func()) string {
+	items := in.consume()
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	return in.p.addStage(opts.Name, func(ctx context.Context, fail func(error)) {
+		defer done()
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for ii := 0; ii < workers; ii++ {
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case v, ok := <-items:
+						if !ok {
+							return
+						}
+						if err := callStage(v, func() error { return f(ctx, v) }); err != nil {
+							fail(err)
+							return
+						}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// callStage calls `f`, returning a panic as a *PanicError for `item`
+func callStage(item any, f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Item: item, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
@@ -0,0 +1,314 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PipelineSuite struct {
+	suite.Suite
+}
+
+func TestPipeline(t *testing.T) {
+	s := new(PipelineSuite)
+	suite.Run(t, s)
+}
+
+// collector is a sink which records the items it receives
+type collector[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+func (c *collector[T]) Job(_ context.Context, v T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = append(c.items, v)
+	return nil
+}
+
+func (c *collector[T]) Items() []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.items
+}
+
+func (s *PipelineSuite) TestRun() {
+	ctx := context.Background()
+	p := NewPipeline()
+
+	numbers := SliceSource(p, generateSeries(10))
+	squares := MapStage(numbers, StageOptions{Workers: 3, Buffer: 2}, func(_ context.Context, v int) (int, error) {
+		return v * v, nil
+	})
+	even := FilterStage(squares, StageOptions{Workers: 2}, func(_ context.Context, v int) (bool, error) {
+		return v%2 == 0, nil
+	})
+	labels := MapStage(even, StageOptions{}, func(_ context.Context, v int) (string, error) {
+		return strconv.Itoa(v), nil
+	})
+	out := &collector[string]{}
+	SinkStage(labels, StageOptions{}, out.Job)
+
+	s.Empty(p.Run(ctx))
+	s.ElementsMatch([]string{"0", "4", "16", "36", "64"}, out.Items())
+}
+
+func (s *PipelineSuite) TestStageErrorCancelsPipeline() {
+	ctx := context.Background()
+	p := NewPipeline()
+
+	// An endless source which only stops when the pipeline is cancelled
+	numbers := SourceStage(p, StageOptions{Name: "numbers"}, func(ctx context.Context, emit func(int) error) error {
+		for ii := 0; ; ii++ {
+			if err := emit(ii); err != nil {
+				return err
+			}
+		}
+	})
+	checked := MapStage(numbers, StageOptions{Name: "check", Workers: 2}, func(_ context.Context, v int) (int, error) {
+		if v == 5 {
+			return 0, fmt.Errorf("%d: %w", v, io.EOF)
+		}
+		return v, nil
+	})
+	out := &collector[int]{}
+	SinkStage(checked, StageOptions{}, out.Job)
+
+	errs := p.Run(ctx)
+	s.Require().Len(errs, 1)
+	var stageErr *StageError
+	s.Require().True(errors.As(errs[0], &stageErr))
+	s.Equal("check", stageErr.Stage)
+	s.True(errors.Is(errs[0], io.EOF))
+	s.Equal(`stage "check": 5: EOF`, errs[0].Error())
+	s.NotContains(out.Items(), 5)
+}
+
+func (s *PipelineSuite) TestFilterError() {
+	ctx := context.Background()
+	p := NewPipeline()
+	even := FilterStage(SliceSource(p, generateSeries(5)), StageOptions{Name: "even"}, func(_ context.Context, v int) (bool, error) {
+		if v == 3 {
+			return false, io.EOF
+		}
+		return v%2 == 0, nil
+	})
+	SinkStage(even, StageOptions{}, func(context.Context, int) error { return nil })
+
+	errs := p.Run(ctx)
+	s.Require().Len(errs, 1)
+	s.Equal(`stage "even": EOF`, errs[0].Error())
+}
+
+func (s *PipelineSuite) TestContextCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPipeline()
+	items := make(chan int)
+	numbers := ChanSource(p, items)
+	SinkStage(numbers, StageOptions{}, func(ctx context.Context, v int) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	go func() {
+		items <- 1
+	}()
+	s.Equal([]error{context.Canceled}, p.Run(ctx))
+}
+
+func (s *PipelineSuite) TestChanSource() {
+	ctx := context.Background()
+	p := NewPipeline()
+	items := make(chan int, 3)
+	LoadChannel(items, 1, 2, 3)
+	close(items)
+
+	out := &collector[int]{}
+	SinkStage(ChanSource(p, items), StageOptions{}, out.Job)
+	s.Empty(p.Run(ctx))
+	s.Equal([]int{1, 2, 3}, out.Items())
+}
+
+func (s *PipelineSuite) TestSourceStopsOnCancel() {
+	ctx := context.Background()
+	p := NewPipeline()
+
+	// The sources block until the pipeline is cancelled by the failing sink
+	block := make(chan int)
+	fromChan := ChanSource(p, block)
+	fromSlice := SliceSource(p, generateSeries(100))
+	SinkStage(fromChan, StageOptions{}, func(context.Context, int) error { return nil })
+	SinkStage(fromSlice, StageOptions{Name: "sink"}, func(context.Context, int) error { return io.EOF })
+
+	errs := p.Run(ctx)
+	s.Require().Len(errs, 1)
+	s.True(errors.Is(errs[0], io.EOF))
+}
+
+func (s *PipelineSuite) TestFanOut() {
+	ctx := context.Background()
+	p := NewPipeline()
+	outs := FanOutStage(SliceSource(p, generateSeries(5)), StageOptions{}, 2)
+	c1, c2 := &collector[int]{}, &collector[int]{}
+	SinkStage(outs[0], StageOptions{}, c1.Job)
+	SinkStage(outs[1], StageOptions{}, c2.Job)
+
+	s.Empty(p.Run(ctx))
+	s.Equal(generateSeries(5), c1.Items())
+	s.Equal(generateSeries(5), c2.Items())
+}
+
+func (s *PipelineSuite) TestFanOutCancelled() {
+	ctx := context.Background()
+	p := NewPipeline()
+	outs := FanOutStage(SliceSource(p, generateSeries(5)), StageOptions{}, 2)
+	SinkStage(outs[0], StageOptions{}, func(context.Context, int) error { return io.EOF })
+	// The second output is never read once the pipeline is cancelled
+	SinkStage(outs[1], StageOptions{}, func(ctx context.Context, _ int) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	errs := p.Run(ctx)
+	s.Len(errs, 1)
+}
+
+func (s *PipelineSuite) TestBatch() {
+	ctx := context.Background()
+	p := NewPipeline()
+	batches := BatchStage(SliceSource(p, generateSeries(7)), StageOptions{}, 3, 0)
+	out := &collector[[]int]{}
+	SinkStage(batches, StageOptions{}, out.Job)
+
+	s.Empty(p.Run(ctx))
+	s.Equal([][]int{{0, 1, 2}, {3, 4, 5}, {6}}, out.Items())
+
+	// A size less than 1 is treated as 1
+	p = NewPipeline()
+	batches = BatchStage(SliceSource(p, generateSeries(2)), StageOptions{}, 0, 0)
+	out = &collector[[]int]{}
+	SinkStage(batches, StageOptions{}, out.Job)
+	s.Empty(p.Run(ctx))
+	s.Equal([][]int{{0}, {1}}, out.Items())
+}
+
+func (s *PipelineSuite) TestBatchLinger() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewPipeline()
+	items := make(chan int)
+	batches := BatchStage(ChanSource(p, items), StageOptions{}, 10, 10*time.Millisecond)
+	received := make(chan []int)
+	SinkStage(batches, StageOptions{}, func(_ context.Context, batch []int) error {
+		received <- batch
+		return nil
+	})
+
+	done := make(chan []error)
+	go func() {
+		done <- p.Run(ctx)
+	}()
+	items <- 1
+	items <- 2
+	s.Equal([]int{1, 2}, <-received)
+
+	// The pipeline can be cancelled while a batch is being built
+	items <- 3
+	cancel()
+	s.Equal([]error{context.Canceled}, <-done)
+}
+
+func (s *PipelineSuite) TestBatchCancelledWhileSending() {
+	ctx := context.Background()
+	p := NewPipeline()
+	batches := BatchStage(SliceSource(p, generateSeries(10)), StageOptions{}, 1, 0)
+	// The batch stage is blocked sending the next batch when the sink fails
+	SinkStage(batches, StageOptions{}, func(context.Context, []int) error {
+		return io.EOF
+	})
+	errs := p.Run(ctx)
+	s.Require().Len(errs, 1)
+	s.True(errors.Is(errs[0], io.EOF))
+}
+
+func (s *PipelineSuite) TestPanic() {
+	ctx := context.Background()
+	p := NewPipeline()
+	SinkStage(SliceSource(p, []int{1}), StageOptions{}, func(context.Context, int) error {
+		panic("boom")
+	})
+	errs := p.Run(ctx)
+	s.Require().Len(errs, 1)
+	var panicErr *PanicError
+	s.Require().True(errors.As(errs[0], &panicErr))
+	s.Equal("boom", panicErr.Value)
+	s.Equal(1, panicErr.Item)
+
+	p = NewPipeline()
+	numbers := SourceStage(p, StageOptions{}, func(context.Context, func(int) error) error {
+		panic("boom")
+	})
+	SinkStage(numbers, StageOptions{}, func(context.Context, int) error { return nil })
+	errs = p.Run(ctx)
+	s.Require().Len(errs, 1)
+	s.Require().True(errors.As(errs[0], &panicErr))
+	s.Nil(panicErr.Item)
+}
+
+func (s *PipelineSuite) TestMisuse() {
+	ctx := context.Background()
+
+	// Outputs must be consumed
+	p := NewPipeline()
+	MapStage(SliceSource(p, []int{1}), StageOptions{Name: "unused"}, func(_ context.Context, v int) (int, error) {
+		return v, nil
+	})
+	errs := p.Run(ctx)
+	s.Require().Len(errs, 1)
+	s.Equal(`the output of stage "unused" is not consumed`, errs[0].Error())
+
+	// Outputs can only be consumed once
+	p = NewPipeline()
+	numbers := SliceSource(p, []int{1})
+	SinkStage(numbers, StageOptions{}, func(context.Context, int) error { return nil })
+	s.PanicsWithValue(`simpleflow: the output of stage "source" is consumed more than once, use FanOutStage to `+
+		`send it to several stages`, func() {
+		SinkStage(numbers, StageOptions{}, func(context.Context, int) error { return nil })
+	})
+
+	// Pipelines can only be run once
+	s.Empty(p.Run(ctx))
+	s.Equal([]error{ErrPipelineStarted}, p.Run(ctx))
+	s.Panics(func() {
+		SliceSource(p, []int{1})
+	})
+}
+
+func (s *PipelineSuite) TestStageNames() {
+	ctx := context.Background()
+	p := NewPipeline()
+	numbers := SliceSource(p, generateSeries(3))
+	outs := FanOutStage(numbers, StageOptions{}, 2)
+	SinkStage(outs[0], StageOptions{}, func(context.Context, int) error { return io.EOF })
+	SinkStage(outs[1], StageOptions{}, func(context.Context, int) error { return io.ErrUnexpectedEOF })
+
+	var names []string
+	for _, err := range p.Run(ctx) {
+		var stageErr *StageError
+		s.Require().True(errors.As(err, &stageErr))
+		names = append(names, stageErr.Stage)
+	}
+	sort.Strings(names)
+	s.Subset([]string{"stage 3", "stage 4"}, names)
+}
@@ -2,7 +2,10 @@ package simpleflow
 
 import (
 	"context"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Job is a function that the slice or channel worker pool executes
@@ -17,44 +20,106 @@ type KeyValue[K comparable, V any] struct {
 	Value V
 }
 
+// poolJob is an item tagged with the position at which it was fed into the worker pool
+type poolJob[T any] struct {
+	index int
+	item  T
+}
+
 // WorkerPoolFromMap starts a worker pool of size `nWorkers` and calls the function `f` for each
 // element in the `items` map
-func WorkerPoolFromMap[K comparable, V any](ctx context.Context, items map[K]V, nWorkers int, f JobKV[K, V]) []error {
+func WorkerPoolFromMap[K comparable, V any](ctx context.Context, items map[K]V, nWorkers int, f JobKV[K, V], opts ...PoolOption) []error {
+	errors, _ := runWorkerPool(ctx, nWorkers, feedMap(items), workFromJobKV(f), opts)
+	return unwrapPoolErrors(errors)
+}
+
+// WorkerPoolFromChan starts a worker pool of size `nWorkers` and calls the function `f` for each
+// element in the `items` channel
+func WorkerPoolFromChan[T any](ctx context.Context, items <-chan T, nWorkers int, f Job[T], opts ...PoolOption) []error {
+	errors, _ := runWorkerPool(ctx, nWorkers, feedChan(items), workFromJob(f), opts)
+	return unwrapPoolErrors(errors)
+}
+
+// WorkerPoolFromSlice starts a worker pool of size `nWorkers` and calls the function `f` for each
+// element in the `items` slice. It returns an array of errors from the jobs.
+func WorkerPoolFromSlice[T any](ctx context.Context, items []T, nWorkers int, f Job[T], opts ...PoolOption) []error {
+	errors, _, err := runSlicePool(ctx, items, nWorkers, workFromJob(f), opts)
+	if err != nil {
+		return append(unwrapPoolErrors(errors), err)
+	}
+	return unwrapPoolErrors(errors)
+}
+
+// workFromJob adapts a Job to be called by the workers of runWorkerPool
+func workFromJob[T any](f Job[T]) func(context.Context, poolJob[T]) error {
+	return func(ctx context.Context, j poolJob[T]) error {
+		return f(ctx, j.item)
+	}
+}
+
+// workFromJobKV adapts a JobKV to be called by the workers of runWorkerPool
+func workFromJobKV[K comparable, V any](f JobKV[K, V]) func(context.Context, poolJob[KeyValue[K, V]]) error {
+	return func(ctx context.Context, j poolJob[KeyValue[K, V]]) error {
+		return f(ctx, j.item.Key, j.item.Value)
+	}
+}
+
+// poolFeeder sends jobs from a source of items to the workers of a worker pool
+type poolFeeder[T any] struct {
+	// feed sends jobs to the workers until there are no more jobs or the context is cancelled. It returns the jobs
+	// that it took from its source but could not send because the context was cancelled.
+	feed func(ctx context.Context, ch chan<- poolJob[T]) (notStarted []poolJob[T])
+	// next, if set, is called by each worker to take the next job from the source instead of `feed`, so that no job
+	// is taken from the source until a worker is ready to start it. It returns false once there are no more jobs or
+	// the context is cancelled.
+	next func(ctx context.Context) (poolJob[T], bool)
+	// waiting returns the number of items in the source that have not been sent to the workers yet
+	waiting func() int
+}
+
+// runWorkerPool starts a worker pool of size `nWorkers` which calls `work` for each job sent by `feed`.
+// It blocks until all jobs are processed or the context is cancelled and returns the errors from the jobs along
+// with the jobs that were never started.
+func runWorkerPool[T any](ctx context.Context, nWorkers int, feed poolFeeder[T], work func(context.Context, poolJob[T]) error, opts []PoolOption) ([]*PoolError[T], []poolJob[T]) {
+	cfg := newPoolConfig(opts)
+	cfg.observeQueue(feed.waiting)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	var errChan = make(chan error)
+	var errChan = make(chan *PoolError[T])
 	var wg sync.WaitGroup
 	wg.Add(nWorkers + 1)
 
+	var nErrors int64
+	deadLetter := deadLetterHandler[T](cfg)
+	// Check the item type of the weight function before starting so that a mismatch panics in the caller
+	weigherFor[T](cfg)
+	onError := func(err *PoolError[T]) {
+		// Cancel the pool before reporting the error so that no other worker picks up a new job
+		if cfg.reachedMaxErrors(&nErrors) {
+			cancel()
+		}
+		deadLetter(err)
+		errChan <- err
+	}
+
 	// Spawn a fixed pool of workers
-	ch := make(chan KeyValue[K, V])
+	ch := make(chan poolJob[T])
 	for ii := 0; ii < nWorkers; ii++ {
 		go func() {
 			defer wg.Done()
-			for {
-				select {
-				case v, ok := <-ch:
-					if !ok {
-						// If the channel is closed, exit
-						return
-					}
-					err := f(ctx, v.Key, v.Value)
-					if err != nil {
-						errChan <- err
-					}
-				case <-ctx.Done():
-					return
-				}
+			if feed.next != nil {
+				pullWorker(ctx, cfg, feed.next, work, onError)
+				return
 			}
-
+			poolWorker(ctx, cfg, ch, nil, work, onError)
 		}()
 	}
 
 	// Start a separate go routine that is pulling errors from the workers and appending them to a slice
 	// This is required so that the error channel does not block. The alternative is to create a buffered
 	// error channel with size len(items)
-	var errors []error
+	var errors []*PoolError[T]
 	errWaitGroup := sync.WaitGroup{}
 	errWaitGroup.Add(1)
 	go func() {
@@ -63,14 +128,11 @@ func WorkerPoolFromMap[K comparable, V any](ctx context.Context, items map[K]V,
 	}()
 
 	// Push items to the workers in a separate go routine in case the channel gets blocked by a canceled worker
+	var notStarted []poolJob[T]
 	go func() {
 		defer wg.Done()
-		for k, v := range items {
-			select {
-			case ch <- KeyValue[K, V]{Key: k, Value: v}:
-			case <-ctx.Done():
-				return
-			}
+		if feed.feed != nil {
+			notStarted = feed.feed(ctx, ch)
 		}
 		close(ch)
 	}()
@@ -81,125 +143,188 @@ func WorkerPoolFromMap[K comparable, V any](ctx context.Context, items map[K]V,
 	close(errChan)
 	errWaitGroup.Wait()
 
-	return errors
+	return errors, notStarted
 }
 
-// WorkerPoolFromChan starts a worker pool of size `nWorkers` and calls the function `f` for each
-// element in the `items` channel
-func WorkerPoolFromChan[T any](ctx context.Context, items <-chan T, nWorkers int, f Job[T]) []error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	var errChan = make(chan error)
-	var wg sync.WaitGroup
-	wg.Add(nWorkers)
-
-	// Spawn a fixed pool of workers
-	for ii := 0; ii < nWorkers; ii++ {
-		go func(ii int) {
-			defer wg.Done()
-			for {
-				select {
-				case v, ok := <-items:
-					if !ok {
-						return
-					}
-					err := f(ctx, v)
-					if err != nil {
-						errChan <- err
-					}
-				case <-ctx.Done():
-					return
-				}
+// poolWorker calls `work` for each job received on `ch` until the channel is closed, the context is cancelled or
+// `stop` is closed. Jobs that fail are passed to `onError`.
+func poolWorker[T any](ctx context.Context, cfg poolConfig, ch <-chan poolJob[T], stop <-chan struct{}, work func(context.Context, poolJob[T]) error, onError func(*PoolError[T])) {
+	// Check for cancellation before each job since select picks randomly between ready cases
+	for ctx.Err() == nil {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		select {
+		case j, ok := <-ch:
+			if !ok {
+				// If the channel is closed, exit
+				return
 			}
-
-		}(ii)
+			handleJob(ctx, cfg, j, work, onError)
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		}
 	}
+}
 
-	// Start a separate go routine that is pulling errors from the workers and appending them to a slice
-	// This is required so that the error channel does not block. The alternative is to create a buffered
-	// error channel with size len(items)
-	var errors []error
-	errWaitGroup := sync.WaitGroup{}
-	errWaitGroup.Add(1)
-	go func() {
-		defer errWaitGroup.Done()
-		errors = ChannelToSlice(errChan)
-	}()
-
-	// Wait for the workers to finish processing their current items
-	wg.Wait()
+// pullWorker calls `work` for each job taken with `next` until there are no more jobs or the context is cancelled.
+// Jobs that fail are passed to `onError`.
+func pullWorker[T any](ctx context.Context, cfg poolConfig, next func(context.Context) (poolJob[T], bool), work func(context.Context, poolJob[T]) error, onError func(*PoolError[T])) {
+	for {
+		j, ok := next(ctx)
+		if !ok {
+			return
+		}
+		handleJob(ctx, cfg, j, work, onError)
+	}
+}
 
-	// Close the error channel and wait for the errors to drain into the slice
-	close(errChan)
-	errWaitGroup.Wait()
+// handleJob runs the job and notifies the observers and hooks of the pool. A failed job is passed to `onError`.
+func handleJob[T any](ctx context.Context, cfg poolConfig, j poolJob[T], work func(context.Context, poolJob[T]) error, onError func(*PoolError[T])) {
+	cfg.notifyStart(j.index)
+	start := time.Now()
+	attempts, err := runJob(ctx, cfg, j, work)
+	cfg.notifyDone(j.index, err, time.Since(start))
+	if err != nil {
+		onError(&PoolError[T]{Item: j.item, Index: j.index, Attempts: attempts, Err: err})
+	}
+	if cfg.afterJob != nil {
+		cfg.afterJob(j.index, err)
+	}
+}
 
-	return errors
+// runJob calls `work` for the job, retrying it if the pool has a retry policy. It returns the number of attempts made.
+func runJob[T any](ctx context.Context, cfg poolConfig, j poolJob[T], work func(context.Context, poolJob[T]) error) (int, error) {
+	if cfg.retry == nil {
+		err := callWork(ctx, cfg, j, work)
+		return retryAttempts(err), err
+	}
+	return cfg.retry.do(ctx, func(ctx context.Context) error {
+		return callWork(ctx, cfg, j, work)
+	})
 }
 
-// WorkerPoolFromSlice starts a worker pool of size `nWorkers` and calls the function `f` for each
-// element in the `items` slice. It returns an array of errors from the jobs.
-func WorkerPoolFromSlice[T any](ctx context.Context, items []T, nWorkers int, f Job[T]) []error {
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+// callWork calls `work` for the job once through the circuit breaker if there is one. The capacity for the weight of
+// the item is acquired first so that waiting for it does not use up a rate limit token or a half-open breaker call.
+func callWork[T any](ctx context.Context, cfg poolConfig, j poolJob[T], work func(context.Context, poolJob[T]) error) error {
+	if w := weigherFor[T](cfg); w != nil {
+		release, err := w.acquire(ctx, j.item)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	if cfg.breaker == nil {
+		return callJob(ctx, cfg, j, work)
+	}
+	return cfg.breaker.Do(ctx, func(ctx context.Context) error {
+		return callJob(ctx, cfg, j, work)
+	})
+}
 
-	var errChan = make(chan error)
-	var wg sync.WaitGroup
-	wg.Add(nWorkers + 1)
+// callJob calls `work` for the job once, waiting for the rate limiter if there is one and applying the job timeout.
+// A panic in `work` is returned as a *PanicError unless panic recovery is disabled.
+func callJob[T any](ctx context.Context, cfg poolConfig, j poolJob[T], work func(context.Context, poolJob[T]) error) (err error) {
+	if cfg.limiter != nil {
+		if err := cfg.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if !cfg.noPanicRecovery {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Item: j.item, Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return callWithTimeout(ctx, cfg.jobTimeout, func(ctx context.Context) error {
+		return work(ctx, j)
+	})
+}
 
-	// Spawn a fixed pool of workers
-	ch := make(chan T)
-	for ii := 0; ii < nWorkers; ii++ {
-		go func() {
-			defer wg.Done()
-			for {
-				select {
-				case v, ok := <-ch:
-					if !ok {
-						// If the channel is closed, exit
-						return
-					}
-					err := f(ctx, v)
-					if err != nil {
-						errChan <- err
+// feedSlice returns a poolFeeder that sends each element of `items` to the workers in order. The elements for which
+// `skip` returns true are not sent. If `skip` is nil, all elements are sent.
+func feedSlice[T any](items []T, skip func(index int) bool) poolFeeder[T] {
+	if skip == nil {
+		skip = func(int) bool { return false }
+	}
+	var sent int64
+	return poolFeeder[T]{
+		feed: func(ctx context.Context, ch chan<- poolJob[T]) []poolJob[T] {
+			for ii := 0; ii < len(items); ii++ {
+				if skip(ii) {
+					atomic.AddInt64(&sent, 1)
+					continue
+				}
+				if !sendCtx(ctx, ch, poolJob[T]{index: ii, item: items[ii]}) {
+					var notStarted []poolJob[T]
+					for ; ii < len(items); ii++ {
+						if !skip(ii) {
+							notStarted = append(notStarted, poolJob[T]{index: ii, item: items[ii]})
+						}
 					}
-
-				case <-ctx.Done():
-					return
+					return notStarted
 				}
+				atomic.AddInt64(&sent, 1)
 			}
-		}()
+			return nil
+		},
+		waiting: func() int {
+			return len(items) - int(atomic.LoadInt64(&sent))
+		},
 	}
+}
 
-	// Start a separate go routine that is pulling errors from the workers and appending them to a slice
-	// This is required so that the error channel does not block. The alternative is to create a buffered
-	// error channel with size len(items)
-	var errors []error
-	errWaitGroup := sync.WaitGroup{}
-	errWaitGroup.Add(1)
-	go func() {
-		defer errWaitGroup.Done()
-		errors = ChannelToSlice(errChan)
-	}()
-
-	// Push items to the workers in a separate go routine in case the channel gets blocked by a canceled worker
-	go func() {
-		defer wg.Done()
-		for ii := 0; ii < len(items); ii++ {
-			select {
-			case ch <- items[ii]:
-			case <-ctx.Done():
-				return
+// feedMap returns a poolFeeder that sends each key-value pair of `items` to the workers
+func feedMap[K comparable, V any](items map[K]V) poolFeeder[KeyValue[K, V]] {
+	var sent int64
+	return poolFeeder[KeyValue[K, V]]{
+		feed: func(ctx context.Context, ch chan<- poolJob[KeyValue[K, V]]) []poolJob[KeyValue[K, V]] {
+			var ii int
+			var notStarted []poolJob[KeyValue[K, V]]
+			for k, v := range items {
+				j := poolJob[KeyValue[K, V]]{index: ii, item: KeyValue[K, V]{Key: k, Value: v}}
+				ii++
+				// Once cancelled, continue iterating to collect the remaining key-value pairs
+				if notStarted != nil || !sendCtx(ctx, ch, j) {
+					notStarted = append(notStarted, j)
+					continue
+				}
+				atomic.AddInt64(&sent, 1)
 			}
-		}
-		close(ch)
-	}()
-
-	// Wait for the workers to finish processing their current items
-	wg.Wait()
-	// Close the error channel and wait for the errors to drain into the slie
-	close(errChan)
-	errWaitGroup.Wait()
+			return notStarted
+		},
+		waiting: func() int {
+			return len(items) - int(atomic.LoadInt64(&sent))
+		},
+	}
+}
 
-	return errors
+// feedChan returns a poolFeeder from which the workers read the values of `items` until it is closed. A value is only
+// read once a worker is ready to start it, so when cancelled, the values that remain in `items` are left on the
+// channel.
+func feedChan[T any](items <-chan T) poolFeeder[T] {
+	// mu is held while reading a value so that the values are numbered in the order they are read
+	var mu sync.Mutex
+	var index int
+	return poolFeeder[T]{
+		next: func(ctx context.Context) (poolJob[T], bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			v, ok, err := recvCtx(ctx, items)
+			if err != nil || !ok {
+				return poolJob[T]{}, false
+			}
+			j := poolJob[T]{index: index, item: v}
+			index++
+			return j, true
+		},
+		waiting: func() int {
+			return len(items)
+		},
+	}
 }
@@ -0,0 +1,109 @@
+package simpleflow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AdaptiveConcurrency adapts the number of workers of a Pool to the health of its jobs using an
+// additive-increase/multiplicative-decrease (AIMD) algorithm. At every interval, the pool grows by one worker if the
+// jobs were healthy and shrinks by the decrease factor otherwise.
+type AdaptiveConcurrency struct {
+	// MinWorkers and MaxWorkers bound the number of workers
+	MinWorkers int
+	MaxWorkers int
+	// Interval is the time between adjustments. It must be positive.
+	Interval time.Duration
+	// LatencyTarget is the maximum average job latency that is considered healthy. Zero means latency is ignored.
+	LatencyTarget time.Duration
+	// MaxErrorRate is the maximum fraction of failed jobs, between 0 and 1, that is considered healthy
+	MaxErrorRate float64
+	// DecreaseFactor is the factor the number of workers is multiplied by when the jobs are unhealthy.
+	// Values outside of (0, 1) default to 0.5.
+	DecreaseFactor float64
+}
+
+// AdaptConcurrency starts adjusting the number of workers in the pool at every interval according to the
+// AdaptiveConcurrency policy. It returns immediately and keeps adjusting until the context is cancelled or
+// the pool is stopped. It panics if the interval of the policy is not positive.
+func (p *Pool[T]) AdaptConcurrency(ctx context.Context, policy AdaptiveConcurrency) {
+	if policy.Interval <= 0 {
+		panic("simpleflow: the interval of an adaptive concurrency policy must be positive")
+	}
+	// Start measuring from now so that jobs before the call do not count towards the first interval
+	p.window.reset()
+
+	go func() {
+		ticker := time.NewTicker(policy.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				count, failed, latency := p.window.reset()
+				p.SetWorkers(policy.next(p.Workers(), count, failed, latency))
+			case <-ctx.Done():
+				return
+			case <-p.exited:
+				return
+			}
+		}
+	}()
+}
+
+// next returns the number of workers to use given the number of jobs finished in the last interval, how many of them
+// failed and their average latency
+func (a AdaptiveConcurrency) next(current, count, failed int, latency time.Duration) int {
+	n := current
+	switch {
+	case count == 0:
+		// There is nothing to judge the health of the jobs by
+	case float64(failed)/float64(count) > a.MaxErrorRate || (a.LatencyTarget > 0 && latency > a.LatencyTarget):
+		factor := a.DecreaseFactor
+		if factor <= 0 || factor >= 1 {
+			factor = 0.5
+		}
+		n = int(float64(current) * factor)
+	default:
+		n = current + 1
+	}
+
+	if n < a.MinWorkers {
+		n = a.MinWorkers
+	}
+	if a.MaxWorkers > 0 && n > a.MaxWorkers {
+		n = a.MaxWorkers
+	}
+	return n
+}
+
+// jobWindow accumulates the outcome and latency of jobs over a window of time
+type jobWindow struct {
+	mu      sync.Mutex
+	count   int
+	failed  int
+	latency time.Duration
+}
+
+// observe records the latency and outcome of a job
+func (w *jobWindow) observe(latency time.Duration, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.count++
+	w.latency += latency
+	if err != nil {
+		w.failed++
+	}
+}
+
+// reset starts a new window and returns the number of jobs, failed jobs and average latency of the previous window
+func (w *jobWindow) reset() (count, failed int, latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	count, failed = w.count, w.failed
+	if count > 0 {
+		latency = w.latency / time.Duration(count)
+	}
+	w.count, w.failed, w.latency = 0, 0, 0
+	return count, failed, latency
+}
@@ -0,0 +1,100 @@
+package simpleflow
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AdaptiveConcurrencySuite struct {
+	suite.Suite
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	s := new(AdaptiveConcurrencySuite)
+	suite.Run(t, s)
+}
+
+func (s *AdaptiveConcurrencySuite) TestNext() {
+	policy := AdaptiveConcurrency{
+		MinWorkers:    2,
+		MaxWorkers:    10,
+		LatencyTarget: 100 * time.Millisecond,
+		MaxErrorRate:  0.1,
+	}
+	// Healthy jobs grow the pool by one, up to the maximum
+	s.Equal(5, policy.next(4, 100, 0, 50*time.Millisecond))
+	s.Equal(10, policy.next(10, 100, 10, 50*time.Millisecond))
+	// No jobs leaves the pool unchanged
+	s.Equal(4, policy.next(4, 0, 0, 0))
+	// Errors or latency shrink the pool by half, down to the minimum
+	s.Equal(4, policy.next(8, 100, 11, 50*time.Millisecond))
+	s.Equal(4, policy.next(8, 100, 0, 150*time.Millisecond))
+	s.Equal(2, policy.next(3, 100, 50, 0))
+
+	policy.DecreaseFactor = 0.75
+	s.Equal(6, policy.next(8, 100, 50, 0))
+
+	// Latency is ignored without a target and there is no maximum by default
+	policy = AdaptiveConcurrency{MaxErrorRate: 0.1}
+	s.Equal(21, policy.next(20, 100, 0, time.Hour))
+}
+
+func (s *AdaptiveConcurrencySuite) TestAdaptConcurrency() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failing int32
+	f := func(_ context.Context, v int) error {
+		time.Sleep(time.Millisecond)
+		if atomic.LoadInt32(&failing) == 1 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	pool := NewPool(ctx, 1, 10, f)
+	defer pool.Stop()
+
+	// Keep the pool busy in the background
+	go func() {
+		for ii := 0; pool.Submit(ctx, ii) == nil; ii++ {
+		}
+	}()
+
+	pool.AdaptConcurrency(ctx, AdaptiveConcurrency{
+		MinWorkers:   1,
+		MaxWorkers:   4,
+		Interval:     5 * time.Millisecond,
+		MaxErrorRate: 0.1,
+	})
+
+	// Healthy jobs grow the pool to its maximum
+	s.Eventually(func() bool {
+		return pool.Workers() == 4
+	}, time.Second, time.Millisecond)
+
+	// Failing jobs shrink the pool to its minimum
+	atomic.StoreInt32(&failing, 1)
+	s.Eventually(func() bool {
+		return pool.Workers() == 1
+	}, time.Second, time.Millisecond)
+}
+
+func (s *AdaptiveConcurrencySuite) TestInvalidInterval() {
+	ctx := context.Background()
+	pool := NewPool(ctx, 1, 0, func(context.Context, int) error { return nil })
+	defer pool.Stop()
+
+	// The policy is validated before adjusting in the background, where the panic could not be recovered
+	s.Panics(func() {
+		pool.AdaptConcurrency(ctx, AdaptiveConcurrency{MinWorkers: 1, MaxWorkers: 4})
+	})
+	s.Panics(func() {
+		pool.AdaptConcurrency(ctx, AdaptiveConcurrency{Interval: -time.Second})
+	})
+	s.Equal(1, pool.Workers())
+}
@@ -0,0 +1,131 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BatchJob is a function that the batch worker pool executes for a batch of items
+type BatchJob[T any] func(ctx context.Context, items []T) error
+
+// BatchError can be returned by a BatchJob to report which items of the batch failed. Items that are not in Errors
+// are considered successful.
+type BatchError struct {
+	// Errors maps the index of each failed item within the batch to its error
+	Errors map[int]error
+}
+
+// Error returns the errors of the failed items in the order of the batch
+func (e *BatchError) Error() string {
+	indices := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	msgs := make([]string, len(indices))
+	for ii, index := range indices {
+		msgs[ii] = fmt.Sprintf("item %d: %v", index, e.Errors[index])
+	}
+	return fmt.Sprintf("%d item(s) in batch failed: %s", len(indices), strings.Join(msgs, "; "))
+}
+
+// itemBatch is a batch of items along with the position at which its first item was read from the channel
+type itemBatch[T any] struct {
+	start int
+	items []T
+}
+
+// WorkerPoolFromChanInBatches starts a worker pool of size `nWorkers` and calls the function `f` with batches of the
+// elements in the `items` channel. A batch is dispatched once it has `size` items or once its first item has waited
+// for `linger`, whichever comes first. A `linger` of 0 means batches are only dispatched once they are full or the
+// channel is closed.
+//
+// It returns a *PoolErrors[T] which identifies each failed item along with its position in the channel and the items of
+// the batches that were not started, or nil if no jobs failed and all batches were started. If `f` returns a
+// *BatchError, only the items within it are failed, otherwise every item of the batch is failed with the error.
+// Retries, dead letters and observers apply per batch, with dead letters receiving each failed item. The weight of a
+// batch given to WithWeightedConcurrency is the combined weight of its items.
+func WorkerPoolFromChanInBatches[T any](ctx context.Context, items <-chan T, nWorkers, size int, linger time.Duration, f BatchJob[T], opts ...PoolOption) error {
+	// Send the failed items of each batch to the dead letter handler of the items and weigh each batch by its items
+	expand := func(cfg *poolConfig) {
+		deadLetter := deadLetterHandler[T](*cfg)
+		cfg.deadLetter = func(err *PoolError[itemBatch[T]]) {
+			for _, itemErr := range batchItemErrors(err) {
+				deadLetter(itemErr)
+			}
+		}
+		if w := weigherFor[T](*cfg); w != nil {
+			cfg.weigher = batchWeigher(w)
+		}
+	}
+	opts = append(opts[:len(opts):len(opts)], expand)
+
+	work := func(ctx context.Context, j poolJob[itemBatch[T]]) error {
+		return f(ctx, j.item.items)
+	}
+	batchErrs, batchesNotStarted := runWorkerPool(ctx, nWorkers, feedBatches(items, size, linger), work, opts)
+
+	var errs []*PoolError[T]
+	for _, err := range batchErrs {
+		errs = append(errs, batchItemErrors(err)...)
+	}
+	var notStarted []poolJob[T]
+	for _, j := range batchesNotStarted {
+		for ii, item := range j.item.items {
+			notStarted = append(notStarted, poolJob[T]{index: j.item.start + ii, item: item})
+		}
+	}
+	return newPoolErrors(errs, notStarted)
+}
+
+// batchItemErrors returns an error for each failed item of a failed batch
+func batchItemErrors[T any](err *PoolError[itemBatch[T]]) []*PoolError[T] {
+	batch := err.Item
+	var batchErr *BatchError
+	if !errors.As(err.Err, &batchErr) {
+		errs := make([]*PoolError[T], len(batch.items))
+		for ii, item := range batch.items {
+			errs[ii] = &PoolError[T]{Item: item, Index: batch.start + ii, Attempts: err.Attempts, Err: err.Err}
+		}
+		return errs
+	}
+
+	var errs []*PoolError[T]
+	for ii, item := range batch.items {
+		if itemErr, ok := batchErr.Errors[ii]; ok {
+			errs = append(errs, &PoolError[T]{Item: item, Index: batch.start + ii, Attempts: err.Attempts, Err: itemErr})
+		}
+	}
+	return errs
+}
+
+// feedBatches returns a poolFeeder that groups the values read from `items` into batches of up to `size` items and
+// sends them to the workers. A `size` less than 1 is treated as 1.
+func feedBatches[T any](items <-chan T, size int, linger time.Duration) poolFeeder[itemBatch[T]] {
+	return poolFeeder[itemBatch[T]]{
+		feed: func(ctx context.Context, ch chan<- poolJob[itemBatch[T]]) []poolJob[itemBatch[T]] {
+			// nBatches and nItems count the batches and items that were sent to the workers
+			var nBatches, nItems int
+			pending, _ := collectBatches(ctx, items, size, linger, nil, func(batch []T) bool {
+				j := poolJob[itemBatch[T]]{index: nBatches, item: itemBatch[T]{start: nItems, items: batch}}
+				if !sendCtx(ctx, ch, j) {
+					return false
+				}
+				nBatches++
+				nItems += len(batch)
+				return true
+			})
+			if len(pending) > 0 {
+				return []poolJob[itemBatch[T]]{{index: nBatches, item: itemBatch[T]{start: nItems, items: pending}}}
+			}
+			return nil
+		},
+		waiting: func() int {
+			return len(items)
+		},
+	}
+}
@@ -0,0 +1,196 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type BatchedPoolSuite struct {
+	suite.Suite
+}
+
+func TestBatchedPool(t *testing.T) {
+	s := new(BatchedPoolSuite)
+	suite.Run(t, s)
+}
+
+// recordBatches returns a batch job that records the batches it was called with
+func recordBatches() (BatchJob[int], func() [][]int) {
+	var mu sync.Mutex
+	var batches [][]int
+	f := func(_ context.Context, items []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, items)
+		return nil
+	}
+	return f, func() [][]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return batches
+	}
+}
+
+// loadedChan returns a closed channel holding `items`
+func loadedChan(items []int) <-chan int {
+	ch := make(chan int, len(items))
+	LoadChannel(ch, items...)
+	close(ch)
+	return ch
+}
+
+func (s *BatchedPoolSuite) TestBatchSize() {
+	ctx := context.Background()
+	f, batches := recordBatches()
+	err := WorkerPoolFromChanInBatches(ctx, loadedChan(generateSeries(10)), 1, 3, 0, f)
+	s.NoError(err)
+	s.Equal([][]int{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, {9}}, batches())
+
+	// A size less than 1 is treated as 1
+	f, batches = recordBatches()
+	err = WorkerPoolFromChanInBatches(ctx, loadedChan(generateSeries(2)), 1, 0, 0, f)
+	s.NoError(err)
+	s.Equal([][]int{{0}, {1}}, batches())
+}
+
+func (s *BatchedPoolSuite) TestLinger() {
+	ctx := context.Background()
+	items := make(chan int)
+	received := make(chan []int)
+	f := func(_ context.Context, batch []int) error {
+		received <- batch
+		return nil
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- WorkerPoolFromChanInBatches(ctx, items, 1, 10, 10*time.Millisecond, f)
+	}()
+
+	// The partial batch is dispatched once the linger has passed
+	items <- 1
+	items <- 2
+	s.Equal([]int{1, 2}, <-received)
+
+	items <- 3
+	s.Equal([]int{3}, <-received)
+
+	close(items)
+	s.NoError(<-done)
+}
+
+func (s *BatchedPoolSuite) TestPartialFailure() {
+	ctx := context.Background()
+	f := func(_ context.Context, batch []int) error {
+		batchErr := &BatchError{Errors: map[int]error{}}
+		for ii, v := range batch {
+			if v%3 == 0 {
+				batchErr.Errors[ii] = fmt.Errorf("%d: %w", v, io.EOF)
+			}
+		}
+		if len(batchErr.Errors) > 0 {
+			return batchErr
+		}
+		return nil
+	}
+	err := WorkerPoolFromChanInBatches(ctx, loadedChan(generateSeries(8)), 2, 4, 0, f)
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{0, 3, 6}, poolErrs.Items())
+	for _, pe := range poolErrs.Errors {
+		s.Equal(pe.Item, pe.Index)
+		s.Equal(1, pe.Attempts)
+	}
+	s.True(errors.Is(err, io.EOF))
+}
+
+func (s *BatchedPoolSuite) TestBatchFailure() {
+	ctx := context.Background()
+	items := []int{10, 11, 12, 13, 14}
+	f := func(_ context.Context, batch []int) error {
+		if batch[0] == 12 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+	policy := RetryPolicy{MaxAttempts: 2}
+	err := WorkerPoolFromChanInBatches(ctx, loadedChan(items), 1, 2, 0, f, WithRetry(policy))
+
+	// Every item of the failed batch is reported
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{12, 13}, poolErrs.Items())
+	s.Equal(2, poolErrs.Errors[0].Index)
+	s.Equal(3, poolErrs.Errors[1].Index)
+	s.Equal(2, poolErrs.Errors[0].Attempts)
+	s.True(errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func (s *BatchedPoolSuite) TestDeadLetters() {
+	ctx := context.Background()
+	f := func(_ context.Context, batch []int) error {
+		return &BatchError{Errors: map[int]error{0: io.EOF}}
+	}
+	deadLetters := make(chan *PoolError[int], 10)
+	err := WorkerPoolFromChanInBatches(ctx, loadedChan(generateSeries(6)), 2, 2, 0, f, WithDeadLetterChan(deadLetters))
+	s.Error(err)
+	close(deadLetters)
+
+	var failed []int
+	for pe := range deadLetters {
+		failed = append(failed, pe.Item)
+	}
+	s.ElementsMatch([]int{0, 2, 4}, failed)
+}
+
+func (s *BatchedPoolSuite) TestCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	items := make(chan int, 10)
+	LoadChannel(items, generateSeries(5)...)
+	f := func(_ context.Context, batch []int) error {
+		cancel()
+		return io.EOF
+	}
+	err := WorkerPoolFromChanInBatches(ctx, items, 1, 2, 0, f)
+
+	// The batch that was waiting for the worker is not started. Items that were never read remain on the channel.
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{0, 1}, poolErrs.Items())
+	close(items)
+	notStarted := append(poolErrs.NotStarted, ChannelToSlice(items)...)
+	s.Equal([]int{2, 3, 4}, notStarted)
+}
+
+func (s *BatchedPoolSuite) TestCancelWithPartialBatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make(chan int, 1)
+	items <- 1
+	f, batches := recordBatches()
+
+	// The partial batch is never full so it is abandoned and reported as not started when the pool is cancelled
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := WorkerPoolFromChanInBatches(ctx, items, 1, 2, 0, f)
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Empty(poolErrs.Errors)
+	s.Equal([]int{1}, poolErrs.NotStarted)
+	s.Empty(batches())
+}
+
+func (s *BatchedPoolSuite) TestBatchErrorString() {
+	err := &BatchError{Errors: map[int]error{3: io.EOF, 1: io.ErrUnexpectedEOF}}
+	s.Equal("2 item(s) in batch failed: item 1: unexpected EOF; item 3: EOF", err.Error())
+}
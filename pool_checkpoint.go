@@ -0,0 +1,201 @@
+package simpleflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Checkpoint records which items of a slice worker pool have been processed successfully
+type Checkpoint struct {
+	// Watermark is the number of leading items that are processed. Every item with an index below it is processed.
+	Watermark int `json:"watermark"`
+	// Completed holds the sorted indices above the watermark that were processed out of order
+	Completed []int `json:"completed,omitempty"`
+}
+
+// CheckpointStore loads and saves the checkpoint of a slice worker pool
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or an empty Checkpoint if none was saved
+	Load() (Checkpoint, error)
+	// Save replaces the saved checkpoint
+	Save(Checkpoint) error
+}
+
+// FileCheckpointStore is a CheckpointStore that saves the checkpoint as JSON to a file
+type FileCheckpointStore struct {
+	path string
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore which saves the checkpoint to the file at `path`
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load reads the checkpoint from the file. It returns an empty Checkpoint if the file does not exist.
+func (s *FileCheckpointStore) Load() (Checkpoint, error) {
+	var cp Checkpoint
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(data, &cp)
+	return cp, err
+}
+
+// Save writes the checkpoint to a temporary file and renames it over the file so that a crash while saving
+// does not corrupt the previous checkpoint
+func (s *FileCheckpointStore) Save(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// WithCheckpoint records the items of a slice worker pool that were processed successfully to `store`, so that
+// running the worker pool again over the same slice with the same store skips them. Failed items are not recorded
+// and are processed again. The checkpoint is saved at most once per `interval` while the pool is running and once
+// more when it finishes, including when it is cancelled. An interval of 0 saves the checkpoint after every item.
+//
+// It only applies to WorkerPoolFromSlice, WorkerPoolFromSliceE and WorkerPoolFromSliceWithResults. The error from
+// loading or saving the checkpoint is returned along with the job errors. WorkerPoolFromSliceE returns it as is if no
+// jobs failed and all items were started, or in the Checkpoint field of the PoolErrors otherwise. Skipped items are
+// left as the zero value in the results of WorkerPoolFromSliceWithResults.
+func WithCheckpoint(store CheckpointStore, interval time.Duration) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.checkpoint = store
+		cfg.checkpointInterval = interval
+	}
+}
+
+// runSlicePool runs a worker pool over `items`, skipping the items that are recorded in the checkpoint of the pool.
+// Along with the results of runWorkerPool, it returns the error from loading or saving the checkpoint.
+func runSlicePool[T any](ctx context.Context, items []T, nWorkers int, work func(context.Context, poolJob[T]) error, opts []PoolOption) ([]*PoolError[T], []poolJob[T], error) {
+	cfg := newPoolConfig(opts)
+	if cfg.checkpoint == nil {
+		errs, notStarted := runWorkerPool(ctx, nWorkers, feedSlice(items, nil), work, opts)
+		return errs, notStarted, nil
+	}
+
+	cp, err := cfg.checkpoint.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading checkpoint: %w", err)
+	}
+	tracker := newCheckpointTracker(cp, cfg.checkpoint, cfg.checkpointInterval)
+
+	// Record each item once its job succeeds
+	record := func(cfg *poolConfig) {
+		cfg.afterJob = func(index int, err error) {
+			if err == nil {
+				tracker.complete(index)
+			}
+		}
+	}
+	opts = append([]PoolOption{record}, opts...)
+	errs, notStarted := runWorkerPool(ctx, nWorkers, feedSlice(items, tracker.processed), work, opts)
+
+	if err := tracker.save(); err != nil {
+		return errs, notStarted, fmt.Errorf("saving checkpoint: %w", err)
+	}
+	return errs, notStarted, nil
+}
+
+// checkpointTracker keeps track of the processed items of a slice worker pool and periodically saves them
+type checkpointTracker struct {
+	store    CheckpointStore
+	interval time.Duration
+
+	// saveMu serializes the saves so that an older checkpoint never overwrites a newer one
+	saveMu sync.Mutex
+
+	mu        sync.Mutex
+	watermark int
+	completed map[int]struct{}
+	lastSave  time.Time
+}
+
+// newCheckpointTracker creates a checkpointTracker which resumes from `cp`
+func newCheckpointTracker(cp Checkpoint, store CheckpointStore, interval time.Duration) *checkpointTracker {
+	t := &checkpointTracker{
+		store:     store,
+		interval:  interval,
+		watermark: cp.Watermark,
+		completed: make(map[int]struct{}, len(cp.Completed)),
+		lastSave:  time.Now(),
+	}
+	for _, index := range cp.Completed {
+		t.completed[index] = struct{}{}
+	}
+	return t
+}
+
+// processed reports whether the item at `index` is processed
+func (t *checkpointTracker) processed(index int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.completed[index]
+	return ok || index < t.watermark
+}
+
+// complete records the item at `index` as processed and saves the checkpoint if the interval has passed.
+// Errors from saving are ignored since the checkpoint is saved again later.
+func (t *checkpointTracker) complete(index int) {
+	t.mu.Lock()
+
+	t.completed[index] = struct{}{}
+	// Advance the watermark past the items that are now contiguous
+	for {
+		if _, ok := t.completed[t.watermark]; !ok {
+			break
+		}
+		delete(t.completed, t.watermark)
+		t.watermark++
+	}
+
+	due := time.Since(t.lastSave) >= t.interval
+	if due {
+		t.lastSave = time.Now()
+	}
+	t.mu.Unlock()
+
+	if due {
+		_ = t.save()
+	}
+}
+
+// save saves the checkpoint. The store is called without holding `mu` so that recording and looking up items does
+// not wait for the checkpoint to be written.
+func (t *checkpointTracker) save() error {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	// The checkpoint is taken once it is this save's turn so that it includes the items completed while waiting
+	t.mu.Lock()
+	cp := t.checkpoint()
+	t.mu.Unlock()
+	return t.store.Save(cp)
+}
+
+// checkpoint returns the current Checkpoint. It must be called with `mu` held.
+func (t *checkpointTracker) checkpoint() Checkpoint {
+	cp := Checkpoint{Watermark: t.watermark}
+	for index := range t.completed {
+		cp.Completed = append(cp.Completed, index)
+	}
+	sort.Ints(cp.Completed)
+	return cp
+}
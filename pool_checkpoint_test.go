@@ -0,0 +1,268 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckpointSuite struct {
+	suite.Suite
+}
+
+func TestCheckpoint(t *testing.T) {
+	s := new(CheckpointSuite)
+	suite.Run(t, s)
+}
+
+// memoryStore is a CheckpointStore that keeps the checkpoint in memory and counts the number of saves
+type memoryStore struct {
+	mu    sync.Mutex
+	cp    Checkpoint
+	saves int
+}
+
+func (m *memoryStore) Load() (Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cp, nil
+}
+
+func (m *memoryStore) Save(cp Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cp = cp
+	m.saves++
+	return nil
+}
+
+// recordItems returns a job that records the items it was called with
+func recordItems() (Job[int], *SyncMap[int, int]) {
+	calls := NewSyncMap(map[int]int{})
+	return func(_ context.Context, v int) error {
+		calls.Lock()
+		defer calls.Unlock()
+		calls.m[v]++
+		return nil
+	}, calls
+}
+
+func (s *CheckpointSuite) TestResumeAfterCancel() {
+	store := NewFileCheckpointStore(filepath.Join(s.T().TempDir(), "checkpoint.json"))
+	items := generateSeries(10)
+	nWorkers := 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := func(_ context.Context, v int) error {
+		if v == 4 {
+			cancel()
+		}
+		return nil
+	}
+	errs := WorkerPoolFromSlice(ctx, items, nWorkers, f, WithCheckpoint(store, time.Hour))
+	s.Empty(errs)
+
+	cp, err := store.Load()
+	s.Require().NoError(err)
+	s.Equal(Checkpoint{Watermark: 5}, cp)
+
+	// Running again only processes the remaining items
+	record, calls := recordItems()
+	errs = WorkerPoolFromSlice(context.Background(), items, nWorkers, record, WithCheckpoint(store, time.Hour))
+	s.Empty(errs)
+	s.Equal(map[int]int{5: 1, 6: 1, 7: 1, 8: 1, 9: 1}, calls.m)
+
+	cp, err = store.Load()
+	s.Require().NoError(err)
+	s.Equal(Checkpoint{Watermark: 10}, cp)
+}
+
+func (s *CheckpointSuite) TestOutOfOrderCompletions() {
+	store := &memoryStore{}
+	items := generateSeries(6)
+	nWorkers := 2
+	f := func(_ context.Context, v int) error {
+		if v == 2 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	errs := WorkerPoolFromSlice(context.Background(), items, nWorkers, f, WithCheckpoint(store, 0))
+	s.Equal([]error{fmt.Errorf("2")}, errs)
+	s.Equal(Checkpoint{Watermark: 2, Completed: []int{3, 4, 5}}, store.cp)
+	// The checkpoint is saved after each successful item and once more at the end
+	s.Equal(6, store.saves)
+
+	// The failed item is processed again
+	record, calls := recordItems()
+	errs = WorkerPoolFromSlice(context.Background(), items, nWorkers, record, WithCheckpoint(store, 0))
+	s.Empty(errs)
+	s.Equal(map[int]int{2: 1}, calls.m)
+	s.Equal(Checkpoint{Watermark: 6}, store.cp)
+}
+
+func (s *CheckpointSuite) TestSaveInterval() {
+	store := &memoryStore{}
+	f, _ := recordItems()
+	errs := WorkerPoolFromSlice(context.Background(), generateSeries(10), 2, f, WithCheckpoint(store, time.Hour))
+	s.Empty(errs)
+	s.Equal(1, store.saves)
+	s.Equal(Checkpoint{Watermark: 10}, store.cp)
+}
+
+func (s *CheckpointSuite) TestWorkerPoolFromSliceE() {
+	store := &memoryStore{cp: Checkpoint{Watermark: 1, Completed: []int{3}}}
+	items := generateSeries(5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := func(_ context.Context, v int) error {
+		if v == 1 {
+			cancel()
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, items, 1, f, WithCheckpoint(store, 0))
+
+	// The items that are already processed are not reported as not started
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{1}, poolErrs.Items())
+	s.Equal([]int{2, 4}, poolErrs.NotStarted)
+
+	f, calls := recordItems()
+	s.NoError(WorkerPoolFromSliceE(context.Background(), items, 1, f, WithCheckpoint(store, 0)))
+	s.Equal(map[int]int{1: 1, 2: 1, 4: 1}, calls.m)
+}
+
+func (s *CheckpointSuite) TestWorkerPoolFromSliceWithResults() {
+	store := &memoryStore{cp: Checkpoint{Watermark: 2}}
+	items := []int{1, 2, 3, 4}
+	f := func(_ context.Context, v int) (int, error) {
+		return v * 10, nil
+	}
+	results, errs := WorkerPoolFromSliceWithResults(context.Background(), items, 2, f, WithCheckpoint(store, 0))
+	s.Empty(errs)
+	s.Equal([]int{0, 0, 30, 40}, results)
+}
+
+func (s *CheckpointSuite) TestLoadError() {
+	path := filepath.Join(s.T().TempDir(), "checkpoint.json")
+	s.Require().NoError(os.WriteFile(path, []byte("not json"), 0o644))
+	store := NewFileCheckpointStore(path)
+
+	f, calls := recordItems()
+	errs := WorkerPoolFromSlice(context.Background(), generateSeries(3), 1, f, WithCheckpoint(store, 0))
+	s.Require().Len(errs, 1)
+	s.Contains(errs[0].Error(), "loading checkpoint")
+	s.Empty(calls.m)
+
+	err := WorkerPoolFromSliceE(context.Background(), generateSeries(3), 1, f, WithCheckpoint(store, 0))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "loading checkpoint")
+	s.Empty(calls.m)
+
+	// A checkpoint which is a directory cannot be read
+	store = NewFileCheckpointStore(s.T().TempDir())
+	_, err = store.Load()
+	s.Error(err)
+}
+
+func (s *CheckpointSuite) TestSaveError() {
+	store := NewFileCheckpointStore(filepath.Join(s.T().TempDir(), "missing", "checkpoint.json"))
+	f, calls := recordItems()
+	errs := WorkerPoolFromSlice(context.Background(), generateSeries(3), 1, f, WithCheckpoint(store, 0))
+	s.Require().Len(errs, 1)
+	s.Contains(errs[0].Error(), "saving checkpoint")
+	s.Len(calls.m, 3)
+
+	err := WorkerPoolFromSliceE(context.Background(), generateSeries(3), 1, f, WithCheckpoint(store, 0))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "saving checkpoint")
+
+	_, errs = WorkerPoolFromSliceWithResults(context.Background(), generateSeries(3), 1,
+		func(context.Context, int) (int, error) { return 0, nil }, WithCheckpoint(store, 0))
+	s.Require().Len(errs, 1)
+	s.Contains(errs[0].Error(), "saving checkpoint")
+
+	s.Run("with failed jobs", func() {
+		f := func(_ context.Context, v int) error {
+			if v == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		}
+		err := WorkerPoolFromSliceE(context.Background(), generateSeries(3), 1, f, WithCheckpoint(store, 0))
+		var poolErrs *PoolErrors[int]
+		s.Require().True(errors.As(err, &poolErrs))
+		s.Equal([]int{1}, poolErrs.Items())
+		s.Require().Error(poolErrs.Checkpoint)
+		s.Contains(poolErrs.Checkpoint.Error(), "saving checkpoint")
+		s.Contains(err.Error(), "saving checkpoint")
+		s.ErrorIs(err, fs.ErrNotExist)
+	})
+}
+
+// blockingStore is a CheckpointStore whose saves wait until `release` is closed
+type blockingStore struct {
+	memoryStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingStore) Save(cp Checkpoint) error {
+	b.saving <- struct{}{}
+	<-b.release
+	return b.memoryStore.Save(cp)
+}
+
+func (s *CheckpointSuite) TestSaveWithoutLock() {
+	store := &blockingStore{saving: make(chan struct{}, 3), release: make(chan struct{})}
+	tracker := newCheckpointTracker(Checkpoint{}, store, 0)
+
+	var wg sync.WaitGroup
+	complete := func(index int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.complete(index)
+		}()
+	}
+	complete(0)
+	<-store.saving
+
+	// Items can be looked up and recorded while the checkpoint is being written
+	s.True(tracker.processed(0))
+	complete(1)
+	s.Eventually(func() bool {
+		return tracker.processed(1)
+	}, time.Second, time.Millisecond)
+
+	close(store.release)
+	wg.Wait()
+	s.Require().NoError(tracker.save())
+	cp, _ := store.Load()
+	s.Equal(Checkpoint{Watermark: 2}, cp)
+}
+
+func (s *CheckpointSuite) TestFileCheckpointStore() {
+	store := NewFileCheckpointStore(filepath.Join(s.T().TempDir(), "checkpoint.json"))
+
+	cp, err := store.Load()
+	s.NoError(err)
+	s.Equal(Checkpoint{}, cp)
+
+	s.NoError(store.Save(Checkpoint{Watermark: 3, Completed: []int{5, 8}}))
+	cp, err = store.Load()
+	s.NoError(err)
+	s.Equal(Checkpoint{Watermark: 3, Completed: []int{5, 8}}, cp)
+}
@@ -0,0 +1,36 @@
+package simpleflow
+
+import "fmt"
+
+// WithDeadLetter calls `handler` with each item whose job failed, after all retries, so that failed items can be
+// stored and replayed later. The PoolError holds the item (a KeyValue for map worker pools), its index, the number
+// of attempts and the final error. The type parameter must match the item type of the worker pool.
+// The handler is called concurrently from the workers.
+func WithDeadLetter[T any](handler func(*PoolError[T])) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.deadLetter = handler
+	}
+}
+
+// WithDeadLetterChan is the same as WithDeadLetter but sends each failed item to the `deadLetters` channel. The
+// channel must be drained by the caller, otherwise the workers block once the channel buffer is full.
+func WithDeadLetterChan[T any](deadLetters chan<- *PoolError[T]) PoolOption {
+	return WithDeadLetter(func(err *PoolError[T]) {
+		deadLetters <- err
+	})
+}
+
+// deadLetterHandler returns the dead letter handler of the worker pool, or a no-op handler if there is none.
+// It panics if the handler does not match the item type of the worker pool since that is a programming error that
+// would otherwise silently drop the failed items.
+func deadLetterHandler[T any](cfg poolConfig) func(*PoolError[T]) {
+	if cfg.deadLetter == nil {
+		return func(*PoolError[T]) {}
+	}
+	handler, ok := cfg.deadLetter.(func(*PoolError[T]))
+	if !ok {
+		panic(fmt.Sprintf("simpleflow: dead letter handler of type %T does not match the worker pool item type %T",
+			cfg.deadLetter, *new(T)))
+	}
+	return handler
+}
@@ -0,0 +1,160 @@
+package simpleflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DeadLetterSuite struct {
+	suite.Suite
+}
+
+func TestDeadLetter(t *testing.T) {
+	s := new(DeadLetterSuite)
+	suite.Run(t, s)
+}
+
+func (s *DeadLetterSuite) TestWorkerPoolFromSlice() {
+	ctx := context.Background()
+	items := generateSeries(10)
+	nWorkers := 3
+	f := func(_ context.Context, v int) error {
+		if v%3 == 0 {
+			return fmt.Errorf("%d: %w", v, io.EOF)
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	var failed []*PoolError[int]
+	handler := func(err *PoolError[int]) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, err)
+	}
+	errs := WorkerPoolFromSlice(ctx, items, nWorkers, f, WithDeadLetter(handler))
+	s.Len(errs, 4)
+
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Index < failed[j].Index })
+	s.Require().Len(failed, 4)
+	for ii, pe := range failed {
+		s.Equal(3*ii, pe.Item)
+		s.Equal(3*ii, pe.Index)
+		s.Equal(1, pe.Attempts)
+		s.ErrorIs(pe, io.EOF)
+	}
+}
+
+func (s *DeadLetterSuite) TestWorkerPoolFromMapWithRetry() {
+	ctx := context.Background()
+	items := map[string]int{"a": 1, "b": 2, "c": 3}
+	nWorkers := 2
+	f := func(_ context.Context, k string, v int) error {
+		if k == "b" {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+
+	deadLetters := make(chan *PoolError[KeyValue[string, int]], len(items))
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	errs := WorkerPoolFromMap(ctx, items, nWorkers, f, WithRetry(policy), WithDeadLetterChan(deadLetters))
+	s.Len(errs, 1)
+	close(deadLetters)
+
+	failed := ChannelToSlice(deadLetters)
+	s.Require().Len(failed, 1)
+	s.Equal(KeyValue[string, int]{Key: "b", Value: 2}, failed[0].Item)
+	s.Equal(3, failed[0].Attempts)
+	s.ErrorIs(failed[0], io.ErrUnexpectedEOF)
+}
+
+func (s *DeadLetterSuite) TestReplay() {
+	ctx := context.Background()
+	items := generateSeries(6)
+	nWorkers := 2
+	f, calls := failNTimes(1)
+	odd := func(ctx context.Context, v int) error {
+		if v%2 == 0 {
+			return nil
+		}
+		return f(ctx, v)
+	}
+
+	deadLetters := make(chan *PoolError[int], len(items))
+	errs := WorkerPoolFromSlice(ctx, items, nWorkers, odd, WithDeadLetterChan(deadLetters))
+	s.Len(errs, 3)
+	close(deadLetters)
+
+	// Replay only the failed items
+	var replay []int
+	for pe := range deadLetters {
+		replay = append(replay, pe.Item)
+	}
+	errs = WorkerPoolFromSlice(ctx, replay, nWorkers, odd)
+	s.Empty(errs)
+	s.Equal(map[int]int{1: 2, 3: 2, 5: 2}, calls.m)
+}
+
+func (s *DeadLetterSuite) TestPanic() {
+	ctx := context.Background()
+	items := []int{0, 1}
+	nWorkers := 1
+	f := func(_ context.Context, v int) error {
+		if v == 1 {
+			panic("boom")
+		}
+		return nil
+	}
+
+	deadLetters := make(chan *PoolError[int], len(items))
+	errs := WorkerPoolFromSlice(ctx, items, nWorkers, f, WithDeadLetterChan(deadLetters))
+	s.Len(errs, 1)
+	close(deadLetters)
+
+	failed := ChannelToSlice(deadLetters)
+	s.Require().Len(failed, 1)
+	s.Equal(1, failed[0].Item)
+	var panicErr *PanicError
+	s.ErrorAs(failed[0], &panicErr)
+}
+
+func (s *DeadLetterSuite) TestPool() {
+	ctx := context.Background()
+	f := func(_ context.Context, v int) error {
+		if v == 2 {
+			return io.EOF
+		}
+		return nil
+	}
+
+	deadLetters := make(chan *PoolError[int], 1)
+	p := NewPool(ctx, 2, 5, f, WithDeadLetterChan(deadLetters))
+	for ii := 0; ii < 5; ii++ {
+		s.Require().NoError(p.Submit(ctx, ii))
+	}
+	s.Error(p.Shutdown(ctx))
+
+	pe := <-deadLetters
+	s.Equal(2, pe.Item)
+	s.Equal(2, pe.Index)
+	s.ErrorIs(pe, io.EOF)
+}
+
+func (s *DeadLetterSuite) TestTypeMismatch() {
+	ctx := context.Background()
+	f := func(context.Context, int) error { return nil }
+
+	s.PanicsWithValue(
+		"simpleflow: dead letter handler of type func(*simpleflow.PoolError[string]) does not match the worker pool item type int",
+		func() {
+			WorkerPoolFromSlice(ctx, []int{1}, 1, f, WithDeadLetter(func(*PoolError[string]) {}))
+		})
+}
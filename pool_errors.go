@@ -0,0 +1,176 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PoolError is an error returned by a job in a worker pool along with the item that caused it
+type PoolError[T any] struct {
+	// Item is the item that the job failed on. For map worker pools this is a KeyValue containing the key.
+	Item T
+	// Index is the position of the item in the slice, or the order in which the item was read from the map or channel
+	Index int
+	// Attempts is the number of times the job was attempted on the item
+	Attempts int
+	// Err is the error returned by the job
+	Err error
+}
+
+// Error returns the error message of the job along with the index of the item that failed
+func (e *PoolError[T]) Error() string {
+	return fmt.Sprintf("item %d failed after %d attempt(s): %v", e.Index, e.Attempts, e.Err)
+}
+
+// Unwrap returns the error returned by the job
+func (e *PoolError[T]) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is returned when a job panics
+type PanicError struct {
+	// Item is the item that the job panicked on
+	Item any
+	// Value is the value that was passed to panic()
+	Value any
+	// Stack is the stack trace of the go routine at the time of the panic
+	Stack []byte
+}
+
+// Error returns the panic value along with the stack trace
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// PoolErrors is the aggregate of all errors returned by the jobs of a worker pool, ordered by item index
+type PoolErrors[T any] struct {
+	Errors []*PoolError[T]
+	// NotStarted are the items that were never processed because the worker pool was stopped early, ordered by index.
+	// For channel worker pools, items remaining on the channel are not included.
+	NotStarted []T
+	// Checkpoint is the error from saving the checkpoint of a slice worker pool started with WithCheckpoint, if any
+	Checkpoint error
+}
+
+// Error returns the error messages of all failed jobs, along with the checkpoint error if any
+func (e *PoolErrors[T]) Error() string {
+	var msg string
+	if len(e.Errors) == 0 {
+		msg = fmt.Sprintf("%d item(s) not started", len(e.NotStarted))
+	} else {
+		messages := make([]string, len(e.Errors))
+		for ii, err := range e.Errors {
+			messages[ii] = err.Error()
+		}
+		msg = fmt.Sprintf("%d job(s) failed: %s", len(e.Errors), strings.Join(messages, "; "))
+		if len(e.NotStarted) > 0 {
+			msg += fmt.Sprintf(" (%d item(s) not started)", len(e.NotStarted))
+		}
+	}
+	if e.Checkpoint != nil {
+		msg += "; " + e.Checkpoint.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the errors of each failed job, followed by the checkpoint error if any
+func (e *PoolErrors[T]) Unwrap() []error {
+	errs := make([]error, len(e.Errors), len(e.Errors)+1)
+	for ii, err := range e.Errors {
+		errs[ii] = err
+	}
+	if e.Checkpoint != nil {
+		errs = append(errs, e.Checkpoint)
+	}
+	return errs
+}
+
+// Is reports whether any of the failed jobs or the checkpoint error matches `target`
+func (e *PoolErrors[T]) Is(target error) bool {
+	for _, err := range e.Unwrap() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first failed job or checkpoint error that matches `target` and sets `target` to that error value
+func (e *PoolErrors[T]) As(target any) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Items returns the items of each failed job
+func (e *PoolErrors[T]) Items() []T {
+	items := make([]T, len(e.Errors))
+	for ii, err := range e.Errors {
+		items[ii] = err.Item
+	}
+	return items
+}
+
+// WorkerPoolFromSliceE is the same as WorkerPoolFromSlice but returns a *PoolErrors[T] error which identifies
+// the items that failed or were not started. It returns nil if no jobs failed and all items were started.
+func WorkerPoolFromSliceE[T any](ctx context.Context, items []T, nWorkers int, f Job[T], opts ...PoolOption) error {
+	errs, notStarted, err := runSlicePool(ctx, items, nWorkers, workFromJob(f), opts)
+	if poolErrs, ok := newPoolErrors(errs, notStarted).(*PoolErrors[T]); ok {
+		poolErrs.Checkpoint = err
+		return poolErrs
+	}
+	return err
+}
+
+// WorkerPoolFromMapE is the same as WorkerPoolFromMap but returns a *PoolErrors[KeyValue[K, V]] error which
+// identifies the key-value pairs that failed or were not started. It returns nil if no jobs failed and all key-value
+// pairs were started.
+func WorkerPoolFromMapE[K comparable, V any](ctx context.Context, items map[K]V, nWorkers int, f JobKV[K, V], opts ...PoolOption) error {
+	return newPoolErrors(runWorkerPool(ctx, nWorkers, feedMap(items), workFromJobKV(f), opts))
+}
+
+// WorkerPoolFromChanE is the same as WorkerPoolFromChan but returns a *PoolErrors[T] error which identifies
+// the items that failed or were not started. It returns nil if no jobs failed and all items were started.
+func WorkerPoolFromChanE[T any](ctx context.Context, items <-chan T, nWorkers int, f Job[T], opts ...PoolOption) error {
+	return newPoolErrors(runWorkerPool(ctx, nWorkers, feedChan(items), workFromJob(f), opts))
+}
+
+// newPoolErrors creates a PoolErrors sorted by item index. It returns a nil error if there are no errors and no items
+// that were not started so that callers can compare against nil.
+func newPoolErrors[T any](errs []*PoolError[T], notStarted []poolJob[T]) error {
+	if len(errs) == 0 && len(notStarted) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Index < errs[j].Index
+	})
+	poolErrs := &PoolErrors[T]{Errors: errs}
+	for _, j := range notStarted {
+		poolErrs.NotStarted = append(poolErrs.NotStarted, j.item)
+	}
+	return poolErrs
+}
+
+// unwrapPoolErrors returns the errors returned by the jobs without the item information
+func unwrapPoolErrors[T any](errs []*PoolError[T]) []error {
+	if errs == nil {
+		return nil
+	}
+	out := make([]error, len(errs))
+	for ii, err := range errs {
+		out[ii] = err.Err
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolErrorsSuite struct {
+	suite.Suite
+}
+
+func TestPoolErrors(t *testing.T) {
+	s := new(PoolErrorsSuite)
+	suite.Run(t, s)
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolFromSliceE() {
+	ctx := context.Background()
+	items := []int{0, 1, 2, 3, 4, 5}
+	nWorkers := 2
+	f := func(_ context.Context, v int) error {
+		if v%2 == 1 {
+			return fmt.Errorf("odd %d: %w", v, io.EOF)
+		}
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, items, nWorkers, f)
+	s.Require().Error(err)
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{1, 3, 5}, poolErrs.Items())
+	for ii, pe := range poolErrs.Errors {
+		s.Equal(2*ii+1, pe.Index)
+		s.Equal(1, pe.Attempts)
+	}
+	s.Len(poolErrs.Unwrap(), 3)
+	s.Equal("3 job(s) failed: item 1 failed after 1 attempt(s): odd 1: EOF; "+
+		"item 3 failed after 1 attempt(s): odd 3: EOF; "+
+		"item 5 failed after 1 attempt(s): odd 5: EOF", err.Error())
+
+	// The aggregate error can be inspected for the underlying job errors
+	s.True(errors.Is(err, io.EOF))
+	s.False(errors.Is(err, os.ErrNotExist))
+
+	var pe *PoolError[int]
+	s.Require().True(errors.As(err, &pe))
+	s.Equal(1, pe.Item)
+	s.ErrorIs(pe, io.EOF)
+
+	var pathErr *os.PathError
+	s.False(poolErrs.As(&pathErr))
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolFromSliceENoErrors() {
+	ctx := context.Background()
+	f := func(_ context.Context, v int) error {
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, []int{0, 1, 2}, 2, f)
+	s.NoError(err)
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolFromSliceECancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	N := 100
+	f := func(_ context.Context, v int) error {
+		cancel()
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, generateSeries(N), 1, f)
+
+	// No job failed but the items after the first are reported as not started
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Empty(poolErrs.Errors)
+	s.Equal(generateSeries(N)[1:], poolErrs.NotStarted)
+	s.Equal("99 item(s) not started", err.Error())
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolFromMapE() {
+	ctx := context.Background()
+	items := map[string]int{"a": 0, "b": 1, "c": 2}
+	nWorkers := 2
+	f := func(_ context.Context, k string, v int) error {
+		if k == "b" {
+			return fmt.Errorf("%s", k)
+		}
+		return nil
+	}
+	err := WorkerPoolFromMapE(ctx, items, nWorkers, f)
+
+	var poolErrs *PoolErrors[KeyValue[string, int]]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]KeyValue[string, int]{{Key: "b", Value: 1}}, poolErrs.Items())
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolFromChanE() {
+	ctx := context.Background()
+	N := 5
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	nWorkers := 2
+	f := func(_ context.Context, v int) error {
+		if v >= 3 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	err := WorkerPoolFromChanE(ctx, itemChan, nWorkers, f)
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{3, 4}, poolErrs.Items())
+	s.Equal(3, poolErrs.Errors[0].Index)
+	s.Equal(4, poolErrs.Errors[1].Index)
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolPanic() {
+	ctx := context.Background()
+	items := []int{0, 1, 2, 3}
+	nWorkers := 2
+	f := func(_ context.Context, v int) error {
+		switch v {
+		case 1:
+			panic("boom")
+		case 2:
+			panic(io.EOF)
+		}
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, items, nWorkers, f)
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{1, 2}, poolErrs.Items())
+
+	var panicErr *PanicError
+	s.Require().True(errors.As(poolErrs.Errors[0], &panicErr))
+	s.Equal(1, panicErr.Item)
+	s.Equal("boom", panicErr.Value)
+	s.Contains(string(panicErr.Stack), "pool_errors_test.go")
+	s.Contains(panicErr.Error(), "panic: boom")
+	s.NoError(panicErr.Unwrap())
+
+	// Panics with an error value can be inspected with errors.Is
+	s.ErrorIs(poolErrs.Errors[1], io.EOF)
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolPanicLegacy() {
+	ctx := context.Background()
+	f := func(_ context.Context, v int) error {
+		panic(v)
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{7}, 1, f)
+	s.Require().Len(errs, 1)
+
+	var panicErr *PanicError
+	s.Require().True(errors.As(errs[0], &panicErr))
+	s.Equal(7, panicErr.Item)
+}
+
+func (s *PoolErrorsSuite) TestWorkerPoolPanicWithResults() {
+	ctx := context.Background()
+	N := 5
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	f := func(_ context.Context, v int) (int, error) {
+		if v == 1 {
+			panic(v)
+		}
+		return v, nil
+	}
+	out := make(chan int, N)
+	errs := WorkerPoolFromChanWithResults(ctx, itemChan, 2, f, out)
+	close(out)
+
+	// The panic does not hold back the results after it
+	s.Equal([]int{0, 2, 3, 4}, ChannelToSlice(out))
+	s.Len(errs, 1)
+}
+
+func (s *PoolErrorsSuite) TestWithoutPanicRecovery() {
+	cfg := newPoolConfig([]PoolOption{WithoutPanicRecovery()})
+	work := func(context.Context, poolJob[int]) error {
+		panic("boom")
+	}
+	s.PanicsWithValue("boom", func() {
+		_ = callWork(context.Background(), cfg, poolJob[int]{}, work)
+	})
+}
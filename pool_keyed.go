@@ -0,0 +1,137 @@
+package simpleflow
+
+import (
+	"context"
+	"sort"
+)
+
+// WorkerPoolFromChanByKey starts a worker pool of size `nWorkers` and calls the function `f` for each element in the
+// `items` channel. Items with the same key, as given by the `key` function, are processed one at a time in the order
+// they were read from the channel, while items with different keys are processed in parallel. Items for a key that is
+// busy are buffered so that they do not hold back the items of other keys.
+func WorkerPoolFromChanByKey[T any, K comparable](ctx context.Context, items <-chan T, nWorkers int, key func(T) K, f Job[T], opts ...PoolOption) []error {
+	// Workers report finished jobs back to the feeder so that it can release the next item with the same key
+	finished := make(chan int)
+	stopped := make(chan struct{})
+	report := func(cfg *poolConfig) {
+		cfg.afterJob = func(index int, _ error) {
+			select {
+			case finished <- index:
+			case <-stopped:
+			}
+		}
+	}
+
+	feed := poolFeeder[T]{
+		feed: func(ctx context.Context, ch chan<- poolJob[T]) []poolJob[T] {
+			defer close(stopped)
+			d := newKeyedDispatcher(key)
+			in := items
+			for in != nil || d.busy() {
+				out, next := d.next(ch)
+				select {
+				case v, ok := <-in:
+					if !ok {
+						in = nil
+						continue
+					}
+					d.add(v)
+				case out <- next:
+					d.dispatched()
+				case index := <-finished:
+					d.finish(index)
+				case <-ctx.Done():
+					return d.notStarted()
+				}
+			}
+			return nil
+		},
+		// Items buffered by the dispatcher are not counted
+		waiting: func() int {
+			return len(items)
+		},
+	}
+
+	errors, _ := runWorkerPool(ctx, nWorkers, feed, workFromJob(f), append([]PoolOption{report}, opts...))
+	return unwrapPoolErrors(errors)
+}
+
+// keyedDispatcher orders jobs such that only one job per key is in progress at a time
+type keyedDispatcher[T any, K comparable] struct {
+	key       func(T) K
+	nextIndex int
+	// ready holds the jobs that can be sent to the workers
+	ready []poolJob[T]
+	// waiting holds the jobs of each key that has a job in progress or ready. The presence of a key means it is busy.
+	waiting map[K][]poolJob[T]
+	// inProgress holds the key of each job that was sent to the workers
+	inProgress map[int]K
+}
+
+// newKeyedDispatcher creates a keyedDispatcher which gets the key of each item using the `key` function
+func newKeyedDispatcher[T any, K comparable](key func(T) K) *keyedDispatcher[T, K] {
+	return &keyedDispatcher[T, K]{
+		key:        key,
+		waiting:    make(map[K][]poolJob[T]),
+		inProgress: make(map[int]K),
+	}
+}
+
+// add adds a new item, making it ready if no other item with the same key is busy
+func (d *keyedDispatcher[T, K]) add(item T) {
+	j := poolJob[T]{index: d.nextIndex, item: item}
+	d.nextIndex++
+	k := d.key(item)
+	if q, busy := d.waiting[k]; busy {
+		d.waiting[k] = append(q, j)
+		return
+	}
+	d.waiting[k] = nil
+	d.ready = append(d.ready, j)
+}
+
+// next returns the channel and job to send next. The channel is nil if no job is ready, which disables the
+// send in a select statement.
+func (d *keyedDispatcher[T, K]) next(ch chan<- poolJob[T]) (chan<- poolJob[T], poolJob[T]) {
+	if len(d.ready) == 0 {
+		return nil, poolJob[T]{}
+	}
+	return ch, d.ready[0]
+}
+
+// dispatched marks the next ready job as in progress
+func (d *keyedDispatcher[T, K]) dispatched() {
+	j := d.ready[0]
+	d.ready = d.ready[1:]
+	d.inProgress[j.index] = d.key(j.item)
+}
+
+// finish marks the job with the given index as done and makes the next job with the same key ready
+func (d *keyedDispatcher[T, K]) finish(index int) {
+	k := d.inProgress[index]
+	delete(d.inProgress, index)
+	q := d.waiting[k]
+	if len(q) == 0 {
+		delete(d.waiting, k)
+		return
+	}
+	d.ready = append(d.ready, q[0])
+	d.waiting[k] = q[1:]
+}
+
+// busy reports whether there are any jobs that are ready, waiting or in progress
+func (d *keyedDispatcher[T, K]) busy() bool {
+	return len(d.waiting) > 0
+}
+
+// notStarted returns the jobs that were ready or waiting, ordered by index
+func (d *keyedDispatcher[T, K]) notStarted() []poolJob[T] {
+	jobs := append([]poolJob[T]{}, d.ready...)
+	for _, q := range d.waiting {
+		jobs = append(jobs, q...)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].index < jobs[j].index
+	})
+	return jobs
+}
@@ -0,0 +1,140 @@
+package simpleflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type KeyedPoolSuite struct {
+	suite.Suite
+}
+
+func TestKeyedPool(t *testing.T) {
+	s := new(KeyedPoolSuite)
+	suite.Run(t, s)
+}
+
+// update is an update to an entity
+type update struct {
+	id  string
+	seq int
+}
+
+func (s *KeyedPoolSuite) TestWorkerPoolFromChanByKey() {
+	ctx := context.Background()
+	N := 30
+	itemChan := make(chan update, N)
+	for ii := 0; ii < N; ii++ {
+		itemChan <- update{id: fmt.Sprint(ii % 3), seq: ii}
+	}
+	close(itemChan)
+
+	var mu sync.Mutex
+	active := map[string]bool{}
+	processed := map[string][]int{}
+	f := func(_ context.Context, u update) error {
+		mu.Lock()
+		s.False(active[u.id], "updates for %s ran concurrently", u.id)
+		active[u.id] = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		active[u.id] = false
+		processed[u.id] = append(processed[u.id], u.seq)
+		mu.Unlock()
+		if u.seq == 4 {
+			return fmt.Errorf("%d", u.seq)
+		}
+		return nil
+	}
+	key := func(u update) string {
+		return u.id
+	}
+	errs := WorkerPoolFromChanByKey(ctx, itemChan, 3, key, f)
+
+	s.Equal([]error{fmt.Errorf("4")}, errs)
+	// Updates for each key are processed in the order they were received
+	s.Equal(map[string][]int{
+		"0": {0, 3, 6, 9, 12, 15, 18, 21, 24, 27},
+		"1": {1, 4, 7, 10, 13, 16, 19, 22, 25, 28},
+		"2": {2, 5, 8, 11, 14, 17, 20, 23, 26, 29},
+	}, processed)
+}
+
+func (s *KeyedPoolSuite) TestHotKeyDoesNotBlock() {
+	ctx := context.Background()
+	itemChan := make(chan update, 10)
+	for ii := 0; ii < 5; ii++ {
+		itemChan <- update{id: "hot", seq: ii}
+	}
+	for ii := 5; ii < 10; ii++ {
+		itemChan <- update{id: fmt.Sprint(ii), seq: ii}
+	}
+	close(itemChan)
+
+	var mu sync.Mutex
+	var order []string
+	f := func(_ context.Context, u update) error {
+		if u.id == "hot" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		mu.Lock()
+		order = append(order, u.id)
+		mu.Unlock()
+		return nil
+	}
+	key := func(u update) string {
+		return u.id
+	}
+	errs := WorkerPoolFromChanByKey(ctx, itemChan, 2, key, f)
+
+	s.Empty(errs)
+	s.Len(order, 10)
+	// The other keys are processed by the second worker while the hot key is being processed
+	s.Equal("hot", order[len(order)-1])
+	s.NotEqual("hot", order[0])
+}
+
+func (s *KeyedPoolSuite) TestWorkerPoolFromChanByKeyCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	N := 100
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	out := NewSyncMap(map[int]int{})
+	f := func(_ context.Context, v int) error {
+		if v > 2 {
+			cancel()
+			return nil
+		}
+		out.Set(v, v)
+		return nil
+	}
+	key := func(v int) int {
+		return v % 2
+	}
+	errs := WorkerPoolFromChanByKey(ctx, itemChan, 2, key, f)
+	s.Empty(errs)
+	s.NotEqual(N, len(out.m))
+}
+
+func (s *KeyedPoolSuite) TestKeyedDispatcherNotStarted() {
+	d := newKeyedDispatcher(func(v int) int {
+		return v % 2
+	})
+	for ii := 0; ii < 5; ii++ {
+		d.add(ii)
+	}
+	// Item 0 is in progress, item 1 is ready and the rest are waiting on their keys
+	d.dispatched()
+	s.Equal([]poolJob[int]{{index: 1, item: 1}, {index: 2, item: 2}, {index: 3, item: 3}, {index: 4, item: 4}},
+		d.notStarted())
+}
@@ -0,0 +1,193 @@
+package simpleflow
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PoolObserver is notified of the progress of the jobs in a worker pool. Items are identified by their index, which
+// is the position of the item in the slice or the order in which it was read from the map or channel. The methods
+// are called concurrently from the workers and must be safe for concurrent use.
+type PoolObserver interface {
+	// OnStart is called before the job of an item is started
+	OnStart(index int)
+	// OnSuccess is called when the job of an item succeeds
+	OnSuccess(index int, duration time.Duration)
+	// OnError is called when the job of an item fails, after all retries
+	OnError(index int, err error, duration time.Duration)
+	// OnPanic is called instead of OnError when the job of an item fails due to a panic
+	OnPanic(index int, err *PanicError, duration time.Duration)
+}
+
+// queueObserver is implemented by observers that report the number of items waiting to be processed
+type queueObserver interface {
+	observeQueue(waiting func() int)
+}
+
+// WithObserver notifies the observer of the progress of the jobs in the worker pool. The option can be given more
+// than once to add several observers.
+func WithObserver(o PoolObserver) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.observers = append(cfg.observers, o)
+	}
+}
+
+// notifyStart notifies the observers that the job of an item is starting
+func (cfg poolConfig) notifyStart(index int) {
+	for _, o := range cfg.observers {
+		o.OnStart(index)
+	}
+}
+
+// notifyDone notifies the observers that the job of an item is finished
+func (cfg poolConfig) notifyDone(index int, err error, duration time.Duration) {
+	if len(cfg.observers) == 0 {
+		return
+	}
+	var panicErr *PanicError
+	isPanic := errors.As(err, &panicErr)
+	for _, o := range cfg.observers {
+		switch {
+		case err == nil:
+			o.OnSuccess(index, duration)
+		case isPanic:
+			o.OnPanic(index, panicErr, duration)
+		default:
+			o.OnError(index, err, duration)
+		}
+	}
+}
+
+// observeQueue gives the observers that report queue depth a function to get the number of waiting items
+func (cfg poolConfig) observeQueue(waiting func() int) {
+	for _, o := range cfg.observers {
+		if qo, ok := o.(queueObserver); ok {
+			qo.observeQueue(waiting)
+		}
+	}
+}
+
+// PoolStats is a snapshot of the progress of a worker pool
+type PoolStats struct {
+	// Processed is the number of jobs that finished, successfully or not
+	Processed int64
+	// Failed is the number of jobs that returned an error or panicked
+	Failed int64
+	// InFlight is the number of jobs currently running
+	InFlight int64
+	// QueueDepth is the number of items waiting to be started
+	QueueDepth int
+	// Throughput is the number of jobs finished per second since the first job started
+	Throughput float64
+	// P50 and P99 are the median and 99th percentile latencies of the most recent jobs
+	P50 time.Duration
+	P99 time.Duration
+}
+
+// latencySamples is the number of most recent job latencies that PoolMetrics uses to calculate percentiles
+const latencySamples = 1024
+
+// PoolMetrics is a PoolObserver that collects statistics of a worker pool which can be polled while the pool is
+// running. Create it with NewPoolMetrics and pass it to the worker pool with WithObserver.
+type PoolMetrics struct {
+	mu        sync.Mutex
+	start     time.Time
+	processed int64
+	failed    int64
+	inFlight  int64
+	waiting   func() int
+	// latencies is a ring buffer of the most recent job latencies
+	latencies []time.Duration
+	next      int
+}
+
+// NewPoolMetrics creates a new PoolMetrics
+func NewPoolMetrics() *PoolMetrics {
+	return &PoolMetrics{latencies: make([]time.Duration, 0, latencySamples)}
+}
+
+// OnStart records the start of a job
+func (m *PoolMetrics) OnStart(int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.start.IsZero() {
+		m.start = time.Now()
+	}
+	m.inFlight++
+}
+
+// OnSuccess records a successful job
+func (m *PoolMetrics) OnSuccess(_ int, duration time.Duration) {
+	m.finish(duration, false)
+}
+
+// OnError records a failed job
+func (m *PoolMetrics) OnError(_ int, _ error, duration time.Duration) {
+	m.finish(duration, true)
+}
+
+// OnPanic records a job that panicked as failed
+func (m *PoolMetrics) OnPanic(_ int, _ *PanicError, duration time.Duration) {
+	m.finish(duration, true)
+}
+
+// Snapshot returns the current statistics of the worker pool
+func (m *PoolMetrics) Snapshot() PoolStats {
+	m.mu.Lock()
+	stats := PoolStats{
+		Processed: m.processed,
+		Failed:    m.failed,
+		InFlight:  m.inFlight,
+	}
+	if m.waiting != nil {
+		stats.QueueDepth = m.waiting()
+	}
+	if elapsed := time.Since(m.start); !m.start.IsZero() && elapsed > 0 {
+		stats.Throughput = float64(m.processed) / elapsed.Seconds()
+	}
+	latencies := append([]time.Duration{}, m.latencies...)
+	m.mu.Unlock()
+
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	stats.P50 = percentile(latencies, 0.5)
+	stats.P99 = percentile(latencies, 0.99)
+	return stats
+}
+
+// finish records a finished job
+func (m *PoolMetrics) finish(duration time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.processed++
+	if failed {
+		m.failed++
+	}
+	if len(m.latencies) < latencySamples {
+		m.latencies = append(m.latencies, duration)
+	} else {
+		m.latencies[m.next] = duration
+	}
+	m.next = (m.next + 1) % latencySamples
+}
+
+// observeQueue sets the function used to get the queue depth
+func (m *PoolMetrics) observeQueue(waiting func() int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waiting = waiting
+}
+
+// percentile returns the nearest-rank percentile `p` (0 < p <= 1) of the sorted values
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[rank]
+}
@@ -0,0 +1,197 @@
+package simpleflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolMetricsSuite struct {
+	suite.Suite
+}
+
+func TestPoolMetrics(t *testing.T) {
+	s := new(PoolMetricsSuite)
+	suite.Run(t, s)
+}
+
+// recordingObserver records the events it is notified of
+type recordingObserver struct {
+	sync.Mutex
+	started   []int
+	succeeded []int
+	failed    []int
+	panicked  []int
+}
+
+func (o *recordingObserver) OnStart(index int) {
+	o.Lock()
+	defer o.Unlock()
+	o.started = append(o.started, index)
+}
+
+func (o *recordingObserver) OnSuccess(index int, _ time.Duration) {
+	o.Lock()
+	defer o.Unlock()
+	o.succeeded = append(o.succeeded, index)
+}
+
+func (o *recordingObserver) OnError(index int, _ error, _ time.Duration) {
+	o.Lock()
+	defer o.Unlock()
+	o.failed = append(o.failed, index)
+}
+
+func (o *recordingObserver) OnPanic(index int, _ *PanicError, _ time.Duration) {
+	o.Lock()
+	defer o.Unlock()
+	o.panicked = append(o.panicked, index)
+}
+
+func (s *PoolMetricsSuite) TestObserver() {
+	ctx := context.Background()
+	items := []int{0, 1, 2, 3, 4}
+	f := func(_ context.Context, v int) error {
+		switch v {
+		case 1:
+			return fmt.Errorf("%d", v)
+		case 3:
+			panic(v)
+		}
+		return nil
+	}
+	o1, o2 := &recordingObserver{}, &recordingObserver{}
+	WorkerPoolFromSlice(ctx, items, 2, f, WithObserver(o1), WithObserver(o2))
+
+	for _, o := range []*recordingObserver{o1, o2} {
+		s.ElementsMatch([]int{0, 1, 2, 3, 4}, o.started)
+		s.ElementsMatch([]int{0, 2, 4}, o.succeeded)
+		s.Equal([]int{1}, o.failed)
+		s.Equal([]int{3}, o.panicked)
+	}
+}
+
+func (s *PoolMetricsSuite) TestPoolMetrics() {
+	ctx := context.Background()
+	items := generateSeries(5)
+	started := make(chan int, len(items))
+	release := make(chan struct{})
+	f := func(ctx context.Context, v int) error {
+		if err := blockingJob(started, release)(ctx, v); err != nil {
+			return err
+		}
+		if v%2 == 1 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	metrics := NewPoolMetrics()
+	s.Equal(PoolStats{}, metrics.Snapshot())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WorkerPoolFromSlice(ctx, items, 2, f, WithObserver(metrics))
+	}()
+
+	// Two items are in progress and the rest are waiting
+	<-started
+	<-started
+	s.Eventually(func() bool {
+		return metrics.Snapshot().QueueDepth == 3
+	}, time.Second, time.Millisecond)
+	stats := metrics.Snapshot()
+	s.Equal(int64(2), stats.InFlight)
+	s.Equal(int64(0), stats.Processed)
+
+	close(release)
+	<-done
+	stats = metrics.Snapshot()
+	s.Equal(int64(0), stats.InFlight)
+	s.Equal(int64(5), stats.Processed)
+	s.Equal(int64(2), stats.Failed)
+	s.Equal(0, stats.QueueDepth)
+	s.Greater(stats.Throughput, 0.0)
+	s.Greater(stats.P50, time.Duration(0))
+	s.GreaterOrEqual(stats.P99, stats.P50)
+
+	// Panics are counted as failures
+	metrics.OnStart(5)
+	metrics.OnPanic(5, &PanicError{}, time.Millisecond)
+	s.Equal(int64(3), metrics.Snapshot().Failed)
+}
+
+func (s *PoolMetricsSuite) TestQueueDepth() {
+	ctx := context.Background()
+	started := make(chan int, 10)
+	release := make(chan struct{})
+
+	// Channel worker pools report the items remaining on the channel
+	itemChan := make(chan int, 10)
+	LoadChannel(itemChan, generateSeries(10)...)
+	close(itemChan)
+	metrics := NewPoolMetrics()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WorkerPoolFromChan(ctx, itemChan, 1, blockingJob(started, release), WithObserver(metrics))
+	}()
+	<-started
+	s.Eventually(func() bool {
+		// One item is in progress and the rest remain on the channel
+		return metrics.Snapshot().QueueDepth == 9
+	}, time.Second, time.Millisecond)
+	close(release)
+	<-done
+
+	// Map worker pools report the items not yet sent to the workers
+	started = make(chan int, 10)
+	release = make(chan struct{})
+	metrics = NewPoolMetrics()
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		WorkerPoolFromMap(ctx, map[int]int{0: 0, 1: 1, 2: 2}, 1, func(ctx context.Context, k, v int) error {
+			return blockingJob(started, release)(ctx, v)
+		}, WithObserver(metrics))
+	}()
+	<-started
+	s.Eventually(func() bool {
+		// One item is in progress and another is being sent to the worker
+		return metrics.Snapshot().QueueDepth == 2
+	}, time.Second, time.Millisecond)
+	close(release)
+	<-done
+
+	// Pools report the items in their queue
+	started = make(chan int, 10)
+	metrics = NewPoolMetrics()
+	pool := NewPool(ctx, 1, 5, blockingJob(started, nil), WithObserver(metrics))
+	for ii := 0; ii < 3; ii++ {
+		s.NoError(pool.Submit(ctx, ii))
+	}
+	<-started
+	s.Equal(2, metrics.Snapshot().QueueDepth)
+	pool.Stop()
+}
+
+func (s *PoolMetricsSuite) TestLatencyPercentiles() {
+	metrics := NewPoolMetrics()
+	for ii := 1; ii <= latencySamples+100; ii++ {
+		metrics.OnStart(ii)
+		metrics.OnSuccess(ii, time.Duration(ii))
+	}
+	stats := metrics.Snapshot()
+	s.Equal(int64(latencySamples+100), stats.Processed)
+
+	// Only the most recent samples are used
+	s.Equal(time.Duration(100+latencySamples/2), stats.P50)
+	s.Equal(time.Duration(100+1014), stats.P99)
+
+	s.Equal(time.Duration(0), percentile(nil, 0.5))
+	s.Equal(time.Duration(3), percentile([]time.Duration{1, 2, 3, 4}, 0.75))
+}
@@ -0,0 +1,75 @@
+package simpleflow
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PoolOption configures the behaviour of a worker pool
+type PoolOption func(*poolConfig)
+
+// poolConfig holds the configuration of a worker pool
+type poolConfig struct {
+	// maxErrors is the number of errors after which the pool is cancelled. Zero means no limit.
+	maxErrors int
+	// retry is the policy used to retry failed jobs. If nil, jobs are not retried.
+	retry *RetryPolicy
+	// noPanicRecovery lets panics in jobs crash the program instead of being returned as errors
+	noPanicRecovery bool
+	// limiter limits the rate at which jobs are called. If nil, there is no limit.
+	limiter *RateLimiter
+	// breaker is the circuit breaker through which jobs are called. If nil, jobs are called directly.
+	breaker *CircuitBreaker
+	// weigher is a *jobWeigher[T] which limits the combined weight of the running jobs
+	weigher any
+	// jobTimeout is the timeout applied to the context of each job. Zero means no timeout.
+	jobTimeout time.Duration
+	// priorityAging is the time it takes for a waiting item to gain one priority in priority worker pools
+	priorityAging time.Duration
+	// deadLetter is a func(*PoolError[T]) which is called with each failed item
+	deadLetter any
+	// checkpoint records the processed items of slice worker pools so that they can be resumed
+	checkpoint CheckpointStore
+	// checkpointInterval is the minimum time between saves of the checkpoint
+	checkpointInterval time.Duration
+	// observers are notified of the progress of each job
+	observers []PoolObserver
+	// afterJob is called with the index of each item once its job is finished, after all attempts
+	afterJob func(index int, err error)
+}
+
+// newPoolConfig creates a poolConfig with all options applied
+func newPoolConfig(opts []PoolOption) poolConfig {
+	var cfg poolConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// reachedMaxErrors increments the error count and reports whether the pool should be cancelled
+func (cfg poolConfig) reachedMaxErrors(nErrors *int64) bool {
+	n := atomic.AddInt64(nErrors, 1)
+	return cfg.maxErrors > 0 && n >= int64(cfg.maxErrors)
+}
+
+// WithMaxErrors cancels the worker pool once `n` jobs have returned an error. Jobs that are in progress
+// have their context cancelled and no new items are started. A value of `n` < 1 means there is no limit.
+func WithMaxErrors(n int) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.maxErrors = n
+	}
+}
+
+// WithFailFast cancels the worker pool as soon as any job returns an error. It is equivalent to WithMaxErrors(1).
+func WithFailFast() PoolOption {
+	return WithMaxErrors(1)
+}
+
+// WithoutPanicRecovery disables the recovery of panics in jobs. By default, a panic in a job is recovered and returned
+// as a *PanicError. With this option, a panic in a job crashes the program.
+func WithoutPanicRecovery() PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.noPanicRecovery = true
+	}
+}
@@ -0,0 +1,100 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolOptionsSuite struct {
+	suite.Suite
+}
+
+func TestPoolOptions(t *testing.T) {
+	s := new(PoolOptionsSuite)
+	suite.Run(t, s)
+}
+
+func (s *PoolOptionsSuite) TestFailFast() {
+	ctx := context.Background()
+	items := generateSeries(10)
+	out := NewSyncMap(map[int]int{})
+	nWorkers := 1
+	f := func(_ context.Context, v int) error {
+		out.Set(v, v)
+		if v == 3 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, items, nWorkers, f, WithFailFast())
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{3}, poolErrs.Items())
+	s.Equal([]int{4, 5, 6, 7, 8, 9}, poolErrs.NotStarted)
+	s.Equal(map[int]int{0: 0, 1: 1, 2: 2, 3: 3}, out.m)
+	s.Contains(err.Error(), "(6 item(s) not started)")
+}
+
+func (s *PoolOptionsSuite) TestFailFastCancelsRunningJobs() {
+	ctx := context.Background()
+	items := []int{0, 1}
+	nWorkers := 2
+	f := func(ctx context.Context, v int) error {
+		if v == 0 {
+			// Block until the pool is cancelled by the failure of the other item
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return fmt.Errorf("%d", v)
+	}
+	errs := WorkerPoolFromSlice(ctx, items, nWorkers, f, WithFailFast())
+	s.ElementsMatch(errs, []error{fmt.Errorf("1"), context.Canceled})
+}
+
+func (s *PoolOptionsSuite) TestMaxErrors() {
+	ctx := context.Background()
+	N := 10
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	nWorkers := 1
+	f := func(_ context.Context, v int) error {
+		if v%2 == 1 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	err := WorkerPoolFromChanE(ctx, itemChan, nWorkers, f, WithMaxErrors(2))
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{1, 3}, poolErrs.Items())
+	// An item read from the channel but not sent to a worker is reported, the rest remain on the channel
+	s.Equal([]int{4, 5, 6, 7, 8, 9}, append(poolErrs.NotStarted, ChannelToSlice(itemChan)...))
+}
+
+func (s *PoolOptionsSuite) TestFailFastMap() {
+	ctx := context.Background()
+	items := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4, 5: 5}
+	out := NewSyncMap(map[int]int{})
+	nWorkers := 1
+	f := func(_ context.Context, k, v int) error {
+		out.Set(k, v)
+		return fmt.Errorf("%d", k)
+	}
+	err := WorkerPoolFromMapE(ctx, items, nWorkers, f, WithFailFast())
+
+	var poolErrs *PoolErrors[KeyValue[int, int]]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Len(poolErrs.Errors, 1)
+	s.Len(poolErrs.NotStarted, len(items)-1)
+	for _, kv := range poolErrs.NotStarted {
+		s.NotContains(out.m, kv.Key)
+	}
+}
@@ -0,0 +1,146 @@
+package simpleflow
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// WithPriorityAging prevents low priority items from starving in WorkerPoolFromChanByPriority by raising the
+// priority of an item by one for every `every` duration that it waits to be started. It has no effect on other
+// worker pools.
+func WithPriorityAging(every time.Duration) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.priorityAging = every
+	}
+}
+
+// WorkerPoolFromChanByPriority starts a worker pool of size `nWorkers` and calls the function `f` for each element
+// in the `items` channel. Items are read from the channel as soon as they are available and whenever a worker is
+// free, it is given the pending item with the highest priority, as given by the `priority` function. Items with
+// equal priority are started in the order they were read. Use WithPriorityAging to ensure that low priority items
+// are eventually started.
+func WorkerPoolFromChanByPriority[T any](ctx context.Context, items <-chan T, nWorkers int, priority func(T) int, f Job[T], opts ...PoolOption) []error {
+	aging := newPoolConfig(opts).priorityAging
+	start := time.Now()
+
+	// score returns the priority of the item at the time it is read. With aging, the effective priority of every
+	// waiting item grows at the same rate, so the order of the items never changes after they are read and the
+	// score can be offset by the time the item was read instead of being recalculated.
+	score := func(item T) float64 {
+		s := float64(priority(item))
+		if aging > 0 {
+			s -= float64(time.Since(start)) / float64(aging)
+		}
+		return s
+	}
+
+	// queued is the number of items in the priority queue of the feeder
+	var queued int64
+	feed := poolFeeder[T]{
+		feed: func(ctx context.Context, ch chan<- poolJob[T]) []poolJob[T] {
+			var queue priorityQueue[T]
+			in := items
+			var index int
+			push := func(v T) {
+				heap.Push(&queue, prioritizedJob[T]{poolJob: poolJob[T]{index: index, item: v}, score: score(v)})
+				atomic.AddInt64(&queued, 1)
+				index++
+			}
+
+			for in != nil || queue.Len() > 0 {
+				// Read all the items that are immediately available so that the highest priority item is sent next
+			drain:
+				for in != nil {
+					select {
+					case v, ok := <-in:
+						if !ok {
+							in = nil
+							break
+						}
+						push(v)
+					default:
+						break drain
+					}
+				}
+
+				var out chan<- poolJob[T]
+				var next poolJob[T]
+				if queue.Len() > 0 {
+					out, next = ch, queue[0].poolJob
+				}
+				select {
+				case v, ok := <-in:
+					if !ok {
+						in = nil
+						continue
+					}
+					push(v)
+				case out <- next:
+					heap.Pop(&queue)
+					atomic.AddInt64(&queued, -1)
+				case <-ctx.Done():
+					return queue.jobs()
+				}
+			}
+			return nil
+		},
+		// Items waiting in the priority queue are counted along with the items remaining on the channel
+		waiting: func() int {
+			return len(items) + int(atomic.LoadInt64(&queued))
+		},
+	}
+
+	errors, _ := runWorkerPool(ctx, nWorkers, feed, workFromJob(f), opts)
+	return unwrapPoolErrors(errors)
+}
+
+// prioritizedJob is a job with the score used to order it in a priorityQueue
+type prioritizedJob[T any] struct {
+	poolJob[T]
+	score float64
+}
+
+// priorityQueue is a max-heap of jobs ordered by score, then by index. It implements heap.Interface.
+type priorityQueue[T any] []prioritizedJob[T]
+
+func (q priorityQueue[T]) Len() int {
+	return len(q)
+}
+
+func (q priorityQueue[T]) Less(i, j int) bool {
+	if q[i].score != q[j].score {
+		return q[i].score > q[j].score
+	}
+	return q[i].index < q[j].index
+}
+
+func (q priorityQueue[T]) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *priorityQueue[T]) Push(x any) {
+	*q = append(*q, x.(prioritizedJob[T]))
+}
+
+func (q *priorityQueue[T]) Pop() any {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
+
+// jobs returns the jobs in the queue ordered by index
+func (q priorityQueue[T]) jobs() []poolJob[T] {
+	jobs := make([]poolJob[T], len(q))
+	for ii, j := range q {
+		jobs[ii] = j.poolJob
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].index < jobs[j].index
+	})
+	return jobs
+}
@@ -0,0 +1,157 @@
+package simpleflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PriorityPoolSuite struct {
+	suite.Suite
+}
+
+func TestPriorityPool(t *testing.T) {
+	s := new(PriorityPoolSuite)
+	suite.Run(t, s)
+}
+
+// task is an item with a priority
+type task struct {
+	name     string
+	priority int
+}
+
+func taskPriority(t task) int {
+	return t.priority
+}
+
+// recordOrder returns a job that records the order in which tasks are processed
+func recordOrder() (Job[task], func() []string) {
+	var mu sync.Mutex
+	var order []string
+	return func(_ context.Context, t task) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, t.name)
+			return nil
+		}, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return order
+		}
+}
+
+func (s *PriorityPoolSuite) TestWorkerPoolFromChanByPriority() {
+	ctx := context.Background()
+	tasks := []task{{"a", 1}, {"b", 3}, {"c", 2}, {"d", 3}, {"e", 1}}
+	itemChan := make(chan task, len(tasks))
+	LoadChannel(itemChan, tasks...)
+	close(itemChan)
+
+	f, order := recordOrder()
+	errs := WorkerPoolFromChanByPriority(ctx, itemChan, 1, taskPriority, f)
+
+	s.Empty(errs)
+	s.Equal([]string{"b", "d", "c", "a", "e"}, order())
+}
+
+func (s *PriorityPoolSuite) TestPriorityAging() {
+	run := func(opts ...PoolOption) []string {
+		ctx := context.Background()
+		itemChan := make(chan task, 10)
+		started := make(chan int, 10)
+		release := make(chan struct{})
+		record, order := recordOrder()
+		f := func(ctx context.Context, t task) error {
+			if t.name == "blocker" {
+				if err := blockingJob(started, release)(ctx, 0); err != nil {
+					return err
+				}
+			}
+			return record(ctx, t)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			WorkerPoolFromChanByPriority(ctx, itemChan, 1, taskPriority, f, opts...)
+		}()
+
+		// The low priority task waits behind the blocker while the high priority tasks arrive
+		itemChan <- task{"blocker", 100}
+		<-started
+		itemChan <- task{"low", 0}
+		time.Sleep(50 * time.Millisecond)
+		LoadChannel(itemChan, task{"high1", 5}, task{"high2", 5})
+		close(itemChan)
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		<-done
+		return order()
+	}
+
+	s.Equal([]string{"blocker", "high1", "high2", "low"}, run())
+	s.Equal([]string{"blocker", "low", "high1", "high2"}, run(WithPriorityAging(time.Millisecond)))
+}
+
+func (s *PriorityPoolSuite) TestWorkerPoolFromChanByPriorityCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	N := 100
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	out := NewSyncMap(map[int]int{})
+	f := func(_ context.Context, v int) error {
+		if v < N-2 {
+			cancel()
+			return nil
+		}
+		out.Set(v, v)
+		return nil
+	}
+	priority := func(v int) int {
+		return v
+	}
+	errs := WorkerPoolFromChanByPriority(ctx, itemChan, 2, priority, f)
+	s.Empty(errs)
+	s.NotEqual(N, len(out.m))
+}
+
+func (s *PriorityPoolSuite) TestQueueDepth() {
+	ctx := context.Background()
+	itemChan := make(chan int, 10)
+	LoadChannel(itemChan, generateSeries(10)...)
+	close(itemChan)
+
+	started := make(chan int, 10)
+	release := make(chan struct{})
+	metrics := NewPoolMetrics()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		priority := func(v int) int { return v }
+		WorkerPoolFromChanByPriority(ctx, itemChan, 1, priority, blockingJob(started, release), WithObserver(metrics))
+	}()
+	<-started
+
+	// The items read into the priority queue are still waiting to be started
+	s.Eventually(func() bool {
+		return metrics.Snapshot().QueueDepth == 9
+	}, time.Second, time.Millisecond)
+	close(release)
+	<-done
+	s.Equal(0, metrics.Snapshot().QueueDepth)
+}
+
+func (s *PriorityPoolSuite) TestPriorityQueueJobs() {
+	q := priorityQueue[int]{
+		{poolJob: poolJob[int]{index: 2, item: 20}, score: 3},
+		{poolJob: poolJob[int]{index: 0, item: 0}, score: 1},
+		{poolJob: poolJob[int]{index: 1, item: 10}, score: 2},
+	}
+	s.Equal([]poolJob[int]{{index: 0, item: 0}, {index: 1, item: 10}, {index: 2, item: 20}}, q.jobs())
+}
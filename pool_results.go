@@ -0,0 +1,141 @@
+package simpleflow
+
+import (
+	"context"
+	"sync"
+)
+
+// JobResult is a function that the slice or channel worker pool executes which produces a result
+type JobResult[T, R any] func(ctx context.Context, item T) (R, error)
+
+// JobKVResult is a function that the map worker pool executes which produces a result
+type JobKVResult[K comparable, V, R any] func(ctx context.Context, k K, v V) (R, error)
+
+// WorkerPoolFromSliceWithResults starts a worker pool of size `nWorkers` and calls the function `f` for each
+// element in the `items` slice. The results are returned in a slice that is aligned with `items`, such that the result
+// of `items[i]` is stored at index `i`. Items that returned an error or were not processed are left as the zero value.
+func WorkerPoolFromSliceWithResults[T, R any](ctx context.Context, items []T, nWorkers int, f JobResult[T, R], opts ...PoolOption) ([]R, []error) {
+	results := make([]R, len(items))
+	errors, _, err := runSlicePool(ctx, items, nWorkers, func(ctx context.Context, j poolJob[T]) error {
+		r, err := f(ctx, j.item)
+		if err != nil {
+			return err
+		}
+		// Each index is only ever written by a single worker so no locking is required
+		results[j.index] = r
+		return nil
+	}, opts)
+	if err != nil {
+		return results, append(unwrapPoolErrors(errors), err)
+	}
+	return results, unwrapPoolErrors(errors)
+}
+
+// WorkerPoolFromMapWithResults starts a worker pool of size `nWorkers` and calls the function `f` for each
+// element in the `items` map. The results are returned in a map with the same keys as `items`. Keys that returned an
+// error or were not processed are omitted from the results.
+func WorkerPoolFromMapWithResults[K comparable, V, R any](ctx context.Context, items map[K]V, nWorkers int, f JobKVResult[K, V, R], opts ...PoolOption) (map[K]R, []error) {
+	var mu sync.Mutex
+	results := make(map[K]R, len(items))
+	errors, _ := runWorkerPool(ctx, nWorkers, feedMap(items), func(ctx context.Context, j poolJob[KeyValue[K, V]]) error {
+		r, err := f(ctx, j.item.Key, j.item.Value)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		results[j.item.Key] = r
+		mu.Unlock()
+		return nil
+	}, opts)
+	return results, unwrapPoolErrors(errors)
+}
+
+// WorkerPoolFromChanWithResults starts a worker pool of size `nWorkers` and calls the function `f` for each
+// element in the `items` channel. The results are pushed onto the `out` channel in the same order that the items were
+// read from `items`, even though the workers may finish out of order. Items that returned an error are skipped.
+// This operation blocks until all items are processed and their results are written to `out`, or the context is
+// cancelled, in which case the results that are not written to `out` yet are dropped. The `out` channel is not closed.
+func WorkerPoolFromChanWithResults[T, R any](ctx context.Context, items <-chan T, nWorkers int, f JobResult[T, R], out chan<- R, opts ...PoolOption) []error {
+	// Results are sent to a single go routine that holds them in a reorder buffer until all
+	// earlier items are done
+	done := make(chan poolResult[R])
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := newReorderBuffer(out)
+		// Once the context is cancelled, the remaining results are drained without being written to `out`
+		for r := range done {
+			buf.Add(ctx, r)
+		}
+	}()
+
+	// The result is reported once the job is finished rather than by the job itself since the job may be retried
+	// or panic. Only successful results are stored.
+	var mu sync.Mutex
+	values := make(map[int]R)
+	report := func(cfg *poolConfig) {
+		cfg.afterJob = func(index int, err error) {
+			mu.Lock()
+			r := values[index]
+			delete(values, index)
+			mu.Unlock()
+			select {
+			case done <- poolResult[R]{index: index, value: r, ok: err == nil}:
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	errors, _ := runWorkerPool(ctx, nWorkers, feedChan(items), func(ctx context.Context, j poolJob[T]) error {
+		r, err := f(ctx, j.item)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		values[j.index] = r
+		mu.Unlock()
+		return nil
+	}, append([]PoolOption{report}, opts...))
+
+	close(done)
+	wg.Wait()
+
+	return unwrapPoolErrors(errors)
+}
+
+// poolResult is the outcome of processing the item at position `index`
+type poolResult[R any] struct {
+	index int
+	value R
+	ok    bool
+}
+
+// reorderBuffer holds results that finished out of order and emits them onto a channel in index order
+type reorderBuffer[R any] struct {
+	next    int
+	pending map[int]poolResult[R]
+	out     chan<- R
+}
+
+// newReorderBuffer creates a reorderBuffer that emits results onto `out`, starting at index 0
+func newReorderBuffer[R any](out chan<- R) *reorderBuffer[R] {
+	return &reorderBuffer[R]{pending: make(map[int]poolResult[R]), out: out}
+}
+
+// Add adds a result to the buffer and emits all consecutive results that are ready. Results that are not `ok`
+// hold their position in the order but are not emitted. It stops emitting results once the context is cancelled.
+func (b *reorderBuffer[R]) Add(ctx context.Context, r poolResult[R]) {
+	b.pending[r.index] = r
+	for {
+		next, ok := b.pending[b.next]
+		if !ok {
+			return
+		}
+		delete(b.pending, b.next)
+		b.next++
+		if next.ok && !sendCtx(ctx, b.out, next.value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package simpleflow
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkerPoolResultsSuite struct {
+	suite.Suite
+}
+
+func TestWorkerPoolResults(t *testing.T) {
+	s := new(WorkerPoolResultsSuite)
+	suite.Run(t, s)
+}
+
+func (s *WorkerPoolResultsSuite) TestWorkerPoolFromSliceWithResults() {
+	ctx := context.Background()
+	items := []int{0, 1, 2, 3, 4, 5}
+	nWorkers := 2
+	f := func(_ context.Context, v int) (string, error) {
+		if v == 3 {
+			return "", fmt.Errorf("%d", v)
+		}
+		return fmt.Sprint(v * v), nil
+	}
+	results, errors := WorkerPoolFromSliceWithResults(ctx, items, nWorkers, f)
+
+	s.Equal([]string{"0", "1", "4", "", "16", "25"}, results)
+	s.Equal([]error{fmt.Errorf("3")}, errors)
+}
+
+func (s *WorkerPoolResultsSuite) TestWorkerPoolFromMapWithResults() {
+	ctx := context.Background()
+	items := map[string]int{"a": 0, "b": 1, "c": 2, "d": 3}
+	nWorkers := 2
+	f := func(_ context.Context, k string, v int) (int, error) {
+		if k == "d" {
+			return 0, fmt.Errorf("%s", k)
+		}
+		return v * v, nil
+	}
+	results, errors := WorkerPoolFromMapWithResults(ctx, items, nWorkers, f)
+
+	s.Equal(map[string]int{"a": 0, "b": 1, "c": 4}, results)
+	s.Equal([]error{fmt.Errorf("d")}, errors)
+}
+
+func (s *WorkerPoolResultsSuite) TestWorkerPoolFromChanWithResults() {
+	ctx := context.Background()
+	N := 10
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	nWorkers := 4
+	// Earlier items take longer so that the workers finish out of order
+	f := func(_ context.Context, v int) (int, error) {
+		time.Sleep(time.Duration(N-v) * time.Millisecond)
+		if v%3 == 0 {
+			return 0, fmt.Errorf("%d", v)
+		}
+		return v * v, nil
+	}
+	out := make(chan int, N)
+	errors := WorkerPoolFromChanWithResults(ctx, itemChan, nWorkers, f, out)
+	close(out)
+
+	s.Equal([]int{1, 4, 16, 25, 49, 64}, ChannelToSlice(out))
+	s.ElementsMatch(errors, []error{
+		fmt.Errorf("0"),
+		fmt.Errorf("3"),
+		fmt.Errorf("6"),
+		fmt.Errorf("9"),
+	})
+}
+
+func (s *WorkerPoolResultsSuite) TestWorkerPoolFromChanWithResultsCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	N := 100
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	nWorkers := 2
+	f := func(_ context.Context, v int) (int, error) {
+		if v > 2 {
+			cancel()
+		}
+		return v, nil
+	}
+	out := make(chan int, N)
+	errors := WorkerPoolFromChanWithResults(ctx, itemChan, nWorkers, f, out)
+	close(out)
+
+	// Results must still be a contiguous, ordered prefix of the input
+	results := ChannelToSlice(out)
+	s.Less(len(results), N)
+	s.Equal(generateSeries(len(results)), results)
+	s.Empty(errors)
+}
+
+func (s *WorkerPoolResultsSuite) TestWorkerPoolFromChanWithResultsNotRead() {
+	ctx, cancel := context.WithCancel(context.Background())
+	N := 10
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	// Nothing reads from `out` so the pool can only return once the context is cancelled
+	out := make(chan int)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	f := func(_ context.Context, v int) (int, error) {
+		return v, nil
+	}
+	errors := WorkerPoolFromChanWithResults(ctx, itemChan, 2, f, out)
+	s.Empty(errors)
+	s.Len(out, 0)
+}
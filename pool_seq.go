@@ -0,0 +1,56 @@
+//go:build go1.23
+
+package simpleflow
+
+import (
+	"context"
+	"iter"
+)
+
+// WorkerPoolFromSeq starts a worker pool of size `nWorkers` and calls the function `f` for each
+// element of the `items` sequence. Elements are pulled from the sequence as workers become available, so the
+// sequence is never materialized. The iteration is stopped early if the context is cancelled.
+func WorkerPoolFromSeq[T any](ctx context.Context, items iter.Seq[T], nWorkers int, f Job[T], opts ...PoolOption) []error {
+	errors, _ := runWorkerPool(ctx, nWorkers, feedSeq(items), workFromJob(f), opts)
+	return unwrapPoolErrors(errors)
+}
+
+// WorkerPoolFromSeq2 starts a worker pool of size `nWorkers` and calls the function `f` for each
+// key-value pair of the `items` sequence. Pairs are pulled from the sequence as workers become available, so the
+// sequence is never materialized. The iteration is stopped early if the context is cancelled.
+func WorkerPoolFromSeq2[K comparable, V any](ctx context.Context, items iter.Seq2[K, V], nWorkers int, f JobKV[K, V], opts ...PoolOption) []error {
+	errors, _ := runWorkerPool(ctx, nWorkers, feedSeq2(items), workFromJobKV(f), opts)
+	return unwrapPoolErrors(errors)
+}
+
+// feedSeq returns a poolFeeder that sends each element of `items` to the workers in order. Since the length of the
+// sequence is unknown, no items are reported as waiting.
+func feedSeq[T any](items iter.Seq[T]) poolFeeder[T] {
+	return poolFeeder[T]{
+		feed: func(ctx context.Context, ch chan<- poolJob[T]) []poolJob[T] {
+			var ii int
+			for v := range items {
+				j := poolJob[T]{index: ii, item: v}
+				ii++
+				if !sendCtx(ctx, ch, j) {
+					return []poolJob[T]{j}
+				}
+			}
+			return nil
+		},
+		waiting: func() int {
+			return 0
+		},
+	}
+}
+
+// feedSeq2 returns a poolFeeder that sends each key-value pair of `items` to the workers in order
+func feedSeq2[K comparable, V any](items iter.Seq2[K, V]) poolFeeder[KeyValue[K, V]] {
+	return feedSeq(func(yield func(KeyValue[K, V]) bool) {
+		for k, v := range items {
+			if !yield(KeyValue[K, V]{Key: k, Value: v}) {
+				return
+			}
+		}
+	})
+}
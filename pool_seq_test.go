@@ -0,0 +1,117 @@
+//go:build go1.23
+
+package simpleflow
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SeqSuite struct {
+	suite.Suite
+}
+
+func TestSeq(t *testing.T) {
+	s := new(SeqSuite)
+	suite.Run(t, s)
+}
+
+func (s *SeqSuite) TestWorkerPoolFromSeq() {
+	ctx := context.Background()
+	items := generateSeries(10)
+	out := NewSyncMap(map[int]int{})
+	nWorkers := 3
+	f := func(_ context.Context, v int) error {
+		out.Set(v, v)
+		if v%3 == 0 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	errs := WorkerPoolFromSeq(ctx, slices.Values(items), nWorkers, f)
+	s.ElementsMatch([]error{fmt.Errorf("0"), fmt.Errorf("3"), fmt.Errorf("6"), fmt.Errorf("9")}, errs)
+	s.Len(out.m, 10)
+}
+
+func (s *SeqSuite) TestWorkerPoolFromSeqStopsIteration() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// An endless sequence, like a cursor over a very large table
+	var pulled int
+	counter := func(yield func(int) bool) {
+		for ii := 0; ; ii++ {
+			pulled++
+			if !yield(ii) {
+				return
+			}
+		}
+	}
+	f := func(_ context.Context, v int) error {
+		if v == 4 {
+			cancel()
+		}
+		return nil
+	}
+	errs := WorkerPoolFromSeq(ctx, counter, 1, f)
+	s.Empty(errs)
+	// The item pulled while the worker was busy is not processed and the iteration stops
+	s.Equal(6, pulled)
+}
+
+func (s *SeqSuite) TestWorkerPoolFromSeqQueueDepth() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := func(_ context.Context, v int) error {
+		cancel()
+		return fmt.Errorf("%d", v)
+	}
+	var metrics = NewPoolMetrics()
+	errs := WorkerPoolFromSeq(ctx, slices.Values([]int{1, 2, 3}), 1, f, WithObserver(metrics))
+	s.Equal([]error{fmt.Errorf("1")}, errs)
+	s.Equal(0, metrics.Snapshot().QueueDepth)
+}
+
+func (s *SeqSuite) TestWorkerPoolFromSeq2() {
+	ctx := context.Background()
+	items := map[string]int{"a": 1, "b": 2, "c": 3}
+	out := NewSyncMap(map[string]int{})
+	nWorkers := 2
+	f := func(_ context.Context, k string, v int) error {
+		out.Set(k, v)
+		if k == "b" {
+			return fmt.Errorf("%s", k)
+		}
+		return nil
+	}
+	errs := WorkerPoolFromSeq2(ctx, maps.All(items), nWorkers, f)
+	s.Equal([]error{fmt.Errorf("b")}, errs)
+	s.Equal(items, out.m)
+}
+
+func (s *SeqSuite) TestWorkerPoolFromSeq2StopsIteration() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	items := []string{"a", "b", "c", "d"}
+	f := func(_ context.Context, k int, v string) error {
+		cancel()
+		return nil
+	}
+	var pulled []int
+	seq := func(yield func(int, string) bool) {
+		for k, v := range slices.All(items) {
+			pulled = append(pulled, k)
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+	errs := WorkerPoolFromSeq2(ctx, seq, 1, f)
+	s.Empty(errs)
+	s.Equal([]int{0, 1}, pulled)
+}
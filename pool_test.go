@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"sync"
 	"testing"
+	"time"
 )
 
 type SyncMap[K comparable, V any] struct {
@@ -134,6 +135,29 @@ func (s *WorkerPoolSuite) TestWorkerPoolFromChanCancelled() {
 	s.Empty(errors)
 }
 
+func (s *WorkerPoolSuite) TestWorkerPoolFromChanCancelledKeepsItems() {
+	// Every value is either processed or left on the channel, none are taken without being processed
+	for ii := 0; ii < 20; ii++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		N := 10
+		itemChan := make(chan int, N)
+		LoadChannel(itemChan, generateSeries(N)...)
+		close(itemChan)
+
+		out := NewSyncMap(map[int]int{})
+		f := func(_ context.Context, v int) error {
+			time.Sleep(time.Millisecond)
+			out.Set(v, v)
+			cancel()
+			return nil
+		}
+		errors := WorkerPoolFromChan(ctx, itemChan, 1, f)
+		s.Empty(errors)
+		s.Len(out.m, 1)
+		s.Len(ChannelToSlice(itemChan), N-1)
+	}
+}
+
 func (s *WorkerPoolSuite) TestWorkerPoolFromChanWithErrors() {
 	ctx := context.Background()
 	N := 5
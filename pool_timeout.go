@@ -0,0 +1,60 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrJobTimeout is matched by the error of a job that did not finish within the job timeout of the worker pool
+var ErrJobTimeout = errors.New("job timed out")
+
+// JobTimeoutError is returned for a job that failed after its context reached the job timeout of the worker pool.
+// It matches ErrJobTimeout with errors.Is.
+type JobTimeoutError struct {
+	// Timeout is the job timeout of the worker pool
+	Timeout time.Duration
+	// Err is the error returned by the job
+	Err error
+}
+
+// Error returns the error of the job along with the timeout
+func (e *JobTimeoutError) Error() string {
+	return fmt.Sprintf("%v after %v: %v", ErrJobTimeout, e.Timeout, e.Err)
+}
+
+// Unwrap returns the error returned by the job
+func (e *JobTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether `target` is ErrJobTimeout
+func (e *JobTimeoutError) Is(target error) bool {
+	return target == ErrJobTimeout
+}
+
+// WithJobTimeout gives each job of the worker pool a context which is cancelled after `timeout`. When retrying,
+// each attempt gets its own timeout. A job that fails once its timeout is reached returns a *JobTimeoutError.
+// Jobs must respect the cancellation of their context for the timeout to free up the worker.
+func WithJobTimeout(timeout time.Duration) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.jobTimeout = timeout
+	}
+}
+
+// callWithTimeout calls `f` with a context which is cancelled after `timeout`. If `f` fails because of the timeout
+// rather than the cancellation of `ctx`, its error is returned in a *JobTimeoutError. A timeout <= 0 means no timeout.
+func callWithTimeout(ctx context.Context, timeout time.Duration, f func(context.Context) error) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := f(jobCtx)
+	if err != nil && errors.Is(jobCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return &JobTimeoutError{Timeout: timeout, Err: err}
+	}
+	return err
+}
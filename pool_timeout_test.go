@@ -0,0 +1,118 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type JobTimeoutSuite struct {
+	suite.Suite
+}
+
+func TestJobTimeout(t *testing.T) {
+	s := new(JobTimeoutSuite)
+	suite.Run(t, s)
+}
+
+func (s *JobTimeoutSuite) TestWorkerPoolFromSlice() {
+	ctx := context.Background()
+	items := generateSeries(4)
+	nWorkers := 1
+	f := func(ctx context.Context, v int) error {
+		if v%2 == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, items, nWorkers, f, WithJobTimeout(10*time.Millisecond))
+
+	// The hung jobs do not block the remaining items
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{1, 3}, poolErrs.Items())
+	s.True(errors.Is(err, ErrJobTimeout))
+	s.True(errors.Is(err, context.DeadlineExceeded))
+
+	var timeoutErr *JobTimeoutError
+	s.Require().True(errors.As(poolErrs.Errors[0], &timeoutErr))
+	s.Equal(10*time.Millisecond, timeoutErr.Timeout)
+	s.Equal("job timed out after 10ms: context deadline exceeded", timeoutErr.Error())
+}
+
+func (s *JobTimeoutSuite) TestSuccessIgnoresTimeout() {
+	ctx := context.Background()
+	f := func(ctx context.Context, v int) error {
+		<-ctx.Done()
+		// The job succeeds despite its context being done
+		return nil
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{1}, 1, f, WithJobTimeout(time.Millisecond))
+	s.Empty(errs)
+}
+
+func (s *JobTimeoutSuite) TestOtherErrors() {
+	ctx := context.Background()
+	f := func(ctx context.Context, v int) error {
+		return fmt.Errorf("%d", v)
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{1}, 1, f, WithJobTimeout(time.Hour))
+	s.Require().Len(errs, 1)
+	s.False(errors.Is(errs[0], ErrJobTimeout))
+}
+
+func (s *JobTimeoutSuite) TestParentCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := func(ctx context.Context, v int) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{1}, 1, f, WithJobTimeout(time.Hour))
+	s.Require().Len(errs, 1)
+	s.False(errors.Is(errs[0], ErrJobTimeout))
+	s.True(errors.Is(errs[0], context.Canceled))
+}
+
+func (s *JobTimeoutSuite) TestRetryEachAttempt() {
+	ctx := context.Background()
+	calls := NewSyncMap(map[int]int{})
+	f := func(ctx context.Context, v int) error {
+		calls.Lock()
+		calls.m[v]++
+		attempt := calls.m[v]
+		calls.Unlock()
+		if attempt < 3 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Retryable: func(err error) bool {
+			return errors.Is(err, ErrJobTimeout)
+		},
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{1}, 1, f, WithJobTimeout(time.Millisecond), WithRetry(policy))
+	s.Empty(errs)
+	s.Equal(3, calls.m[1])
+}
+
+func (s *JobTimeoutSuite) TestPool() {
+	ctx := context.Background()
+	f := func(ctx context.Context, v int) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	p := NewPool(ctx, 1, 1, f, WithJobTimeout(time.Millisecond))
+	s.Require().NoError(p.Submit(ctx, 1))
+	err := p.Shutdown(ctx)
+	s.True(errors.Is(err, ErrJobTimeout))
+}
@@ -0,0 +1,164 @@
+package simpleflow
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithWeightedConcurrency limits the combined weight of the jobs that run at the same time to `capacity`, in
+// addition to the limit on the number of workers. The weight of each item is given by the `weight` function, so
+// that a heavy item takes up more of the capacity than a light one. Weights less than 1 are treated as 1 and weights
+// larger than the capacity are treated as the capacity. Items are started in the order they are received, so a heavy
+// item waiting for capacity holds back the lighter items behind it. The weight is held for each attempt of a job and
+// released between retries. Jobs wait for capacity before waiting for the rate limiter or going through the circuit
+// breaker.
+//
+// The type parameter must match the item type of the worker pool, which is a KeyValue for map worker pools. For
+// WorkerPoolFromChanInBatches, it is the type of the items in the batches and the weight of a batch is the combined
+// weight of its items.
+func WithWeightedConcurrency[T any](capacity int64, weight func(T) int64) PoolOption {
+	return func(cfg *poolConfig) {
+		if capacity < 1 {
+			capacity = 1
+		}
+		cfg.weigher = &jobWeigher[T]{weight: weight, sem: newWeightedSemaphore(capacity)}
+	}
+}
+
+// jobWeigher acquires the weight of each item from a weighted semaphore
+type jobWeigher[T any] struct {
+	weight func(T) int64
+	sem    *weightedSemaphore
+}
+
+// weigherFor returns the jobWeigher of the worker pool, or nil if there is none. It panics if the weight function
+// does not match the item type of the worker pool.
+func weigherFor[T any](cfg poolConfig) *jobWeigher[T] {
+	if cfg.weigher == nil {
+		return nil
+	}
+	w, ok := cfg.weigher.(*jobWeigher[T])
+	if !ok {
+		panic(fmt.Sprintf("simpleflow: weight function of type %T does not match the worker pool item type %T",
+			cfg.weigher, *new(T)))
+	}
+	return w
+}
+
+// acquire blocks until there is capacity for `item` and returns a function which releases it. If the context is
+// cancelled first, the context error is returned.
+func (w *jobWeigher[T]) acquire(ctx context.Context, item T) (func(), error) {
+	n := w.weightOf(item)
+	if err := w.sem.acquire(ctx, n); err != nil {
+		return nil, err
+	}
+	return func() {
+		w.sem.release(n)
+	}, nil
+}
+
+// weightOf returns the weight of `item` bounded between 1 and the capacity
+func (w *jobWeigher[T]) weightOf(item T) int64 {
+	n := w.weight(item)
+	if n < 1 {
+		n = 1
+	}
+	if n > w.sem.capacity {
+		n = w.sem.capacity
+	}
+	return n
+}
+
+// batchWeigher returns a jobWeigher for batches of items which shares the capacity of `w`. The weight of a batch is
+// the combined weight of its items.
+func batchWeigher[T any](w *jobWeigher[T]) *jobWeigher[itemBatch[T]] {
+	return &jobWeigher[itemBatch[T]]{
+		weight: func(batch itemBatch[T]) int64 {
+			var n int64
+			for _, item := range batch.items {
+				n += w.weightOf(item)
+			}
+			return n
+		},
+		sem: w.sem,
+	}
+}
+
+// weightedSemaphore is a semaphore whose capacity is acquired in varying amounts. Waiters are served in order.
+type weightedSemaphore struct {
+	capacity int64
+
+	mu   sync.Mutex
+	used int64
+	// waiters holds a *semaphoreWaiter for each blocked call to acquire
+	waiters list.List
+}
+
+// semaphoreWaiter is a call to acquire which is waiting for capacity. `ready` is closed once it is acquired.
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// newWeightedSemaphore creates a weightedSemaphore with the given capacity
+func newWeightedSemaphore(capacity int64) *weightedSemaphore {
+	return &weightedSemaphore{capacity: capacity}
+}
+
+// acquire blocks until `n` of the capacity is acquired or the context is cancelled
+func (s *weightedSemaphore) acquire(ctx context.Context, n int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.waiters.Len() == 0 && s.used+n <= s.capacity {
+		s.used += n
+		s.mu.Unlock()
+		return nil
+	}
+	w := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// The capacity was acquired while being cancelled, so give it back
+			s.used -= n
+		default:
+			s.waiters.Remove(elem)
+		}
+		// Removing a waiter may let the waiters behind it acquire
+		s.notify()
+		return ctx.Err()
+	}
+}
+
+// release gives back `n` of the capacity
+func (s *weightedSemaphore) release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used -= n
+	s.notify()
+}
+
+// notify lets the waiters at the front of the queue acquire while there is capacity. It must be called with `mu` held.
+func (s *weightedSemaphore) notify() {
+	for s.waiters.Len() > 0 {
+		front := s.waiters.Front()
+		w := front.Value.(*semaphoreWaiter)
+		if s.used+w.n > s.capacity {
+			return
+		}
+		s.used += w.n
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
@@ -0,0 +1,234 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type WeightedPoolSuite struct {
+	suite.Suite
+}
+
+func TestWeightedPool(t *testing.T) {
+	s := new(WeightedPoolSuite)
+	suite.Run(t, s)
+}
+
+// weightTracker records the largest combined weight of the jobs running at the same time
+type weightTracker struct {
+	mu      sync.Mutex
+	running int64
+	max     int64
+}
+
+func (w *weightTracker) job(weight func(int) int64) Job[int] {
+	return func(_ context.Context, v int) error {
+		w.mu.Lock()
+		w.running += weight(v)
+		if w.running > w.max {
+			w.max = w.running
+		}
+		w.mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		w.mu.Lock()
+		w.running -= weight(v)
+		w.mu.Unlock()
+		return nil
+	}
+}
+
+func (s *WeightedPoolSuite) TestCapacity() {
+	ctx := context.Background()
+	// Every third item is heavy
+	weight := func(v int) int64 {
+		if v%3 == 0 {
+			return 3
+		}
+		return 1
+	}
+	tracker := &weightTracker{}
+	errs := WorkerPoolFromSlice(ctx, generateSeries(20), 10, tracker.job(weight), WithWeightedConcurrency(4, weight))
+	s.Empty(errs)
+	s.Equal(int64(4), tracker.max)
+}
+
+func (s *WeightedPoolSuite) TestWeightClamped() {
+	ctx := context.Background()
+	tracker := &weightTracker{}
+	one := func(int) int64 { return 1 }
+
+	// An item heavier than the capacity takes up the whole capacity rather than blocking forever
+	heavy := func(v int) int64 { return int64(v) * 100 }
+	clamped := func(v int) int64 {
+		if v == 0 {
+			return 1
+		}
+		return 2
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{0, 1, 2}, 3, tracker.job(clamped), WithWeightedConcurrency(2, heavy))
+	s.Empty(errs)
+	s.Equal(int64(2), tracker.max)
+
+	// A capacity less than 1 is treated as 1
+	tracker = &weightTracker{}
+	errs = WorkerPoolFromSlice(ctx, generateSeries(3), 3, tracker.job(one), WithWeightedConcurrency(0, one))
+	s.Empty(errs)
+	s.Equal(int64(1), tracker.max)
+}
+
+func (s *WeightedPoolSuite) TestMap() {
+	ctx := context.Background()
+	items := map[string]int{"a": 1, "b": 2}
+	weight := func(kv KeyValue[string, int]) int64 {
+		return int64(kv.Value)
+	}
+	out := NewSyncMap(map[string]int{})
+	f := func(_ context.Context, k string, v int) error {
+		out.Set(k, v)
+		return nil
+	}
+	errs := WorkerPoolFromMap(ctx, items, 2, f, WithWeightedConcurrency(2, weight))
+	s.Empty(errs)
+	s.Equal(items, out.m)
+}
+
+func (s *WeightedPoolSuite) TestCancelWhileWaiting() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	weight := func(int) int64 { return 1 }
+	o := &recordingObserver{}
+	f := func(ctx context.Context, v int) error {
+		// Hold the capacity until the other item is waiting for it, then cancel the pool
+		s.Eventually(func() bool {
+			o.Lock()
+			defer o.Unlock()
+			return len(o.started) == 2
+		}, time.Second, time.Millisecond)
+		cancel()
+		<-ctx.Done()
+		return nil
+	}
+	err := WorkerPoolFromSliceE(ctx, []int{0, 1}, 2, f, WithWeightedConcurrency(1, weight), WithObserver(o))
+
+	// The item that was waiting for capacity fails with the context error
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Len(poolErrs.Items(), 1)
+	s.True(errors.Is(err, context.Canceled))
+}
+
+func (s *WeightedPoolSuite) TestPool() {
+	ctx := context.Background()
+	weight := func(v int) int64 { return int64(v) }
+	tracker := &weightTracker{}
+	p := NewPool(ctx, 5, 10, tracker.job(weight), WithWeightedConcurrency(5, weight))
+	for _, v := range []int{3, 2, 4, 1, 5} {
+		s.Require().NoError(p.Submit(ctx, v))
+	}
+	s.NoError(p.Shutdown(ctx))
+	s.Equal(int64(5), tracker.max)
+}
+
+func (s *WeightedPoolSuite) TestBatches() {
+	ctx := context.Background()
+	items := make(chan int, 12)
+	LoadChannel(items, generateSeries(12)...)
+	close(items)
+
+	// Each batch weighs as much as its items combined, so only two batches of 2 items fit in the capacity at once
+	tracker := &weightTracker{}
+	job := tracker.job(func(n int) int64 { return int64(n) })
+	f := func(ctx context.Context, batch []int) error {
+		return job(ctx, len(batch))
+	}
+	one := func(int) int64 { return 1 }
+	err := WorkerPoolFromChanInBatches(ctx, items, 4, 2, 0, f, WithWeightedConcurrency(4, one))
+	s.NoError(err)
+	s.Equal(int64(4), tracker.max)
+}
+
+func (s *WeightedPoolSuite) TestWaitBeforeRateLimit() {
+	ctx := context.Background()
+	limiter := NewRateLimiter(1, time.Hour, 2)
+	weight := func(int) int64 { return 1 }
+
+	// A job waiting for capacity does not take a token from the rate limiter yet
+	var once sync.Once
+	var tokens float64
+	f := func(context.Context, int) error {
+		// Only the first job holds the capacity while the other one waits for it
+		once.Do(func() {
+			time.Sleep(20 * time.Millisecond)
+			limiter.mu.Lock()
+			tokens = limiter.tokens
+			limiter.mu.Unlock()
+		})
+		return nil
+	}
+	errs := WorkerPoolFromSlice(ctx, []int{0, 1}, 2, f, WithWeightedConcurrency(1, weight), WithRateLimit(limiter))
+	s.Empty(errs)
+	s.GreaterOrEqual(tokens, 1.0)
+}
+
+func (s *WeightedPoolSuite) TestTypeMismatch() {
+	ctx := context.Background()
+	f := func(context.Context, int) error { return nil }
+	weight := func(string) int64 { return 1 }
+
+	s.PanicsWithValue(
+		"simpleflow: weight function of type *simpleflow.jobWeigher[string] does not match the worker pool item type int",
+		func() {
+			WorkerPoolFromSlice(ctx, []int{1}, 1, f, WithWeightedConcurrency(1, weight))
+		})
+}
+
+func (s *WeightedPoolSuite) TestSemaphore() {
+	ctx := context.Background()
+	sem := newWeightedSemaphore(3)
+	s.NoError(sem.acquire(ctx, 2))
+
+	// A waiter at the front of the queue holds back the waiters behind it, even if they fit
+	heavyCtx, cancelHeavy := context.WithCancel(ctx)
+	defer cancelHeavy()
+	heavy := make(chan error)
+	go func() {
+		heavy <- sem.acquire(heavyCtx, 3)
+	}()
+	s.Eventually(func() bool {
+		sem.mu.Lock()
+		defer sem.mu.Unlock()
+		return sem.waiters.Len() == 1
+	}, time.Second, time.Millisecond)
+
+	light := make(chan error)
+	go func() {
+		light <- sem.acquire(ctx, 1)
+	}()
+	s.Eventually(func() bool {
+		sem.mu.Lock()
+		defer sem.mu.Unlock()
+		return sem.waiters.Len() == 2
+	}, time.Second, time.Millisecond)
+
+	// Cancelling the heavy waiter lets the waiters behind it acquire
+	cancelHeavy()
+	s.Equal(context.Canceled, <-heavy)
+	s.NoError(<-light)
+
+	// Releasing lets the next waiter acquire
+	go func() {
+		heavy <- sem.acquire(ctx, 3)
+	}()
+	sem.release(3)
+	s.NoError(<-heavy)
+
+	// A cancelled context fails immediately
+	s.Equal(context.Canceled, sem.acquire(heavyCtx, 1))
+}
@@ -0,0 +1,100 @@
+package simpleflow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter. Tokens are added to the bucket at a fixed rate, up to the size of the
+// bucket (burst). Each call to Wait or Allow takes a token from the bucket. It is safe for concurrent use.
+type RateLimiter struct {
+	mu sync.Mutex
+	// rate is the number of tokens added per second
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter which allows `n` events every `interval`, with bursts of up to `burst` events.
+// The bucket starts full. A burst less than 1 is treated as 1. It panics if `n` or `interval` is not positive.
+func NewRateLimiter(n int, interval time.Duration, burst int) *RateLimiter {
+	if n <= 0 || interval <= 0 {
+		panic("simpleflow: the number of events and interval of a rate limiter must be positive")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   float64(n) / interval.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket if one is available and reports whether it did
+func (r *RateLimiter) Allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refill(time.Now())
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// Wait blocks until a token is available and takes it from the bucket. If the context is cancelled first,
+// the context error is returned and no token is taken.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Reserve a token, which may leave the bucket in debt, and wait for the debt to be repaid
+	r.mu.Lock()
+	r.refill(time.Now())
+	r.tokens--
+	var wait time.Duration
+	if r.tokens < 0 {
+		wait = time.Duration(-r.tokens / r.rate * float64(time.Second))
+	}
+	r.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the reserved token so that other callers do not wait for it
+		r.mu.Lock()
+		r.tokens++
+		r.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens accumulated since the last refill. It must be called with `mu` held.
+func (r *RateLimiter) refill(now time.Time) {
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.burst {
+		r.tokens = r.burst
+	}
+	r.last = now
+}
+
+// WithRateLimit limits the rate at which the worker pool calls its job using the given RateLimiter. Each attempt
+// of a job takes a token. The limiter can be shared between worker pools to enforce a combined rate.
+// If the context is cancelled while waiting for a token, the job fails with the context error.
+func WithRateLimit(limiter *RateLimiter) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.limiter = limiter
+	}
+}
@@ -0,0 +1,103 @@
+package simpleflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimiterSuite struct {
+	suite.Suite
+}
+
+func TestRateLimiter(t *testing.T) {
+	s := new(RateLimiterSuite)
+	suite.Run(t, s)
+}
+
+func (s *RateLimiterSuite) TestAllow() {
+	limiter := NewRateLimiter(1, 50*time.Millisecond, 2)
+
+	// The bucket starts full so the burst is allowed immediately
+	s.True(limiter.Allow())
+	s.True(limiter.Allow())
+	s.False(limiter.Allow())
+
+	time.Sleep(60 * time.Millisecond)
+	s.True(limiter.Allow())
+	s.False(limiter.Allow())
+}
+
+func (s *RateLimiterSuite) TestInvalidRate() {
+	// These rates would otherwise never limit anything
+	s.Panics(func() { NewRateLimiter(0, time.Second, 1) })
+	s.Panics(func() { NewRateLimiter(-1, time.Second, 1) })
+	s.Panics(func() { NewRateLimiter(1, 0, 1) })
+	s.Panics(func() { NewRateLimiter(1, -time.Second, 1) })
+}
+
+func (s *RateLimiterSuite) TestWait() {
+	ctx := context.Background()
+	limiter := NewRateLimiter(1, 10*time.Millisecond, 0)
+
+	start := time.Now()
+	for ii := 0; ii < 5; ii++ {
+		s.NoError(limiter.Wait(ctx))
+	}
+	// The first token is available immediately, the rest are 10ms apart
+	s.GreaterOrEqual(time.Since(start), 40*time.Millisecond)
+}
+
+func (s *RateLimiterSuite) TestWaitCancelled() {
+	limiter := NewRateLimiter(1, time.Hour, 1)
+	s.True(limiter.Allow())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(limiter.Wait(ctx), context.DeadlineExceeded)
+	s.ErrorIs(limiter.Wait(ctx), context.DeadlineExceeded)
+
+	// The tokens reserved by the cancelled calls are given back
+	s.InDelta(0, limiter.tokens, 0.01)
+}
+
+func (s *RateLimiterSuite) TestWorkerPoolWithRateLimit() {
+	ctx := context.Background()
+	items := generateSeries(6)
+	out := NewSyncMap(map[int]time.Time{})
+	f := func(_ context.Context, v int) error {
+		out.Set(v, time.Now())
+		return nil
+	}
+	// Allow a burst of 2 jobs, then one job every 20ms
+	limiter := NewRateLimiter(1, 20*time.Millisecond, 2)
+
+	start := time.Now()
+	errs := WorkerPoolFromSlice(ctx, items, 4, f, WithRateLimit(limiter))
+	s.Empty(errs)
+	s.Len(out.m, len(items))
+	s.GreaterOrEqual(time.Since(start), 80*time.Millisecond)
+}
+
+func (s *RateLimiterSuite) TestWorkerPoolWithRateLimitCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter := NewRateLimiter(1, time.Hour, 1)
+	s.True(limiter.Allow())
+
+	var called bool
+	f := func(_ context.Context, v int) error {
+		called = true
+		return nil
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	// The job fails with the context error since it is cancelled while waiting for a token
+	errs := WorkerPoolFromSlice(ctx, []int{0}, 1, f, WithRateLimit(limiter))
+	s.Equal([]error{context.Canceled}, errs)
+	s.False(called)
+}
@@ -0,0 +1,159 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy describes how a failed job is retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times the job is attempted, including the first attempt.
+	// Values less than 1 are treated as 1.
+	MaxAttempts int
+	// InitialBackoff is the time to wait before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the time to wait between attempts. Zero means no cap other than the longest Duration.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after each attempt. Values less than 1 default to 2.
+	Multiplier float64
+	// Jitter is the fraction of the backoff, between 0 and 1, that is randomized to avoid retries being synchronized
+	Jitter float64
+	// Retryable determines whether an error should be retried. If nil, all errors are retried.
+	Retryable func(error) bool
+	// AttemptTimeout is the timeout applied to the context of each attempt. Zero means no timeout.
+	AttemptTimeout time.Duration
+}
+
+// RetryError is returned by a retried job once it is no longer retried
+type RetryError struct {
+	// Attempts is the number of times the job was attempted
+	Attempts int
+	// Err is the error returned by the last attempt
+	Err error
+}
+
+// Error returns the error of the last attempt along with the number of attempts
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// RetryJob wraps the job `f` so that it is retried according to the retry policy. If the job does not succeed,
+// the error of the last attempt is returned in a *RetryError.
+func RetryJob[T any](f Job[T], policy RetryPolicy) Job[T] {
+	return func(ctx context.Context, item T) error {
+		attempts, err := policy.do(ctx, func(ctx context.Context) error {
+			return f(ctx, item)
+		})
+		if err != nil {
+			return &RetryError{Attempts: attempts, Err: err}
+		}
+		return nil
+	}
+}
+
+// RetryJobKV wraps the job `f` so that it is retried according to the retry policy. If the job does not succeed,
+// the error of the last attempt is returned in a *RetryError.
+func RetryJobKV[K comparable, V any](f JobKV[K, V], policy RetryPolicy) JobKV[K, V] {
+	return func(ctx context.Context, k K, v V) error {
+		attempts, err := policy.do(ctx, func(ctx context.Context) error {
+			return f(ctx, k, v)
+		})
+		if err != nil {
+			return &RetryError{Attempts: attempts, Err: err}
+		}
+		return nil
+	}
+}
+
+// WithRetry retries failed jobs in the worker pool according to the retry policy. The number of attempts is
+// reported in PoolError.Attempts.
+func WithRetry(policy RetryPolicy) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.retry = &policy
+	}
+}
+
+// do calls `f` until it succeeds, returns an error that is not retryable, the attempts are exhausted or
+// the context is cancelled. It returns the number of attempts made and the error of the last attempt.
+func (p RetryPolicy) do(ctx context.Context, f func(context.Context) error) (int, error) {
+	var attempt int
+	for {
+		attempt++
+		err := p.attempt(ctx, f)
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+			return attempt, err
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			return attempt, err
+		}
+
+		// Wait before the next attempt, giving up if the context is cancelled in the meantime
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+	}
+}
+
+// attempt calls `f` once with the attempt timeout applied
+func (p RetryPolicy) attempt(ctx context.Context, f func(context.Context) error) error {
+	if p.AttemptTimeout <= 0 {
+		return f(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.AttemptTimeout)
+	defer cancel()
+	return f(ctx)
+}
+
+// backoff returns the time to wait after the given (1-based) attempt has failed
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	// Without a cap, the backoff is still limited to the longest Duration so that it does not overflow
+	limit := float64(math.MaxInt64)
+	if p.MaxBackoff > 0 {
+		limit = float64(p.MaxBackoff)
+	}
+	backoff := float64(p.InitialBackoff)
+	for ii := 1; ii < attempt; ii++ {
+		backoff *= multiplier
+		// Stop growing once the cap is reached to avoid overflowing
+		if backoff >= limit {
+			break
+		}
+	}
+	if backoff > limit {
+		backoff = limit
+	}
+	if p.Jitter > 0 {
+		backoff -= backoff * p.Jitter * rand.Float64()
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which does not fit in a Duration
+	if backoff >= float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(backoff)
+}
+
+// retryAttempts returns the number of attempts recorded in a *RetryError, or 1 if the error was not retried
+func retryAttempts(err error) int {
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		return retryErr.Attempts
+	}
+	return 1
+}
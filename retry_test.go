@@ -0,0 +1,201 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RetrySuite struct {
+	suite.Suite
+}
+
+func TestRetry(t *testing.T) {
+	s := new(RetrySuite)
+	suite.Run(t, s)
+}
+
+// failNTimes returns a job that fails the first `n` times it is called for each item
+func failNTimes(n int) (Job[int], *SyncMap[int, int]) {
+	calls := NewSyncMap(map[int]int{})
+	return func(_ context.Context, v int) error {
+		calls.Lock()
+		defer calls.Unlock()
+		calls.m[v]++
+		if calls.m[v] <= n {
+			return fmt.Errorf("%d: %w", v, io.ErrUnexpectedEOF)
+		}
+		return nil
+	}, calls
+}
+
+func (s *RetrySuite) TestRetryJobSucceeds() {
+	f, calls := failNTimes(2)
+	job := RetryJob(f, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	s.NoError(job(context.Background(), 0))
+	s.Equal(3, calls.m[0])
+}
+
+func (s *RetrySuite) TestRetryJobExhausted() {
+	f, calls := failNTimes(5)
+	job := RetryJob(f, RetryPolicy{MaxAttempts: 3})
+
+	err := job(context.Background(), 0)
+	var retryErr *RetryError
+	s.Require().True(errors.As(err, &retryErr))
+	s.Equal(3, retryErr.Attempts)
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	s.Equal("failed after 3 attempt(s): 0: unexpected EOF", err.Error())
+	s.Equal(3, calls.m[0])
+}
+
+func (s *RetrySuite) TestRetryJobNotRetryable() {
+	f, calls := failNTimes(5)
+	job := RetryJob(f, RetryPolicy{
+		MaxAttempts: 3,
+		Retryable: func(err error) bool {
+			return !errors.Is(err, io.ErrUnexpectedEOF)
+		},
+	})
+
+	err := job(context.Background(), 0)
+	s.ErrorIs(err, io.ErrUnexpectedEOF)
+	s.Equal(1, calls.m[0])
+}
+
+func (s *RetrySuite) TestRetryJobAttemptTimeout() {
+	var calls int32
+	f := func(ctx context.Context, v int) error {
+		atomic.AddInt32(&calls, 1)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	job := RetryJob(f, RetryPolicy{MaxAttempts: 2, AttemptTimeout: time.Millisecond})
+
+	err := job(context.Background(), 0)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.Equal(int32(2), calls)
+}
+
+func (s *RetrySuite) TestRetryJobCancelledDuringBackoff() {
+	ctx, cancel := context.WithCancel(context.Background())
+	f, calls := failNTimes(5)
+	job := RetryJob(f, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err := job(ctx, 0)
+	var retryErr *RetryError
+	s.Require().True(errors.As(err, &retryErr))
+	s.Equal(1, retryErr.Attempts)
+	s.Equal(1, calls.m[0])
+}
+
+func (s *RetrySuite) TestRetryJobKV() {
+	f, calls := failNTimes(1)
+	job := RetryJobKV(func(ctx context.Context, k string, v int) error {
+		return f(ctx, v)
+	}, RetryPolicy{MaxAttempts: 2})
+
+	s.NoError(job(context.Background(), "a", 0))
+	s.Equal(2, calls.m[0])
+
+	job = RetryJobKV(func(ctx context.Context, k string, v int) error {
+		return fmt.Errorf("%s", k)
+	}, RetryPolicy{MaxAttempts: 2})
+	s.Equal(&RetryError{Attempts: 2, Err: fmt.Errorf("a")}, job(context.Background(), "a", 0))
+}
+
+func (s *RetrySuite) TestWorkerPoolWithRetry() {
+	ctx := context.Background()
+	items := []int{0, 1, 2, 3}
+	nWorkers := 2
+
+	// Every item succeeds on its second attempt
+	f, calls := failNTimes(1)
+	err := WorkerPoolFromSliceE(ctx, items, nWorkers, f, WithRetry(RetryPolicy{MaxAttempts: 2}))
+	s.NoError(err)
+	s.Equal(map[int]int{0: 2, 1: 2, 2: 2, 3: 2}, calls.m)
+
+	// Every item fails on all attempts
+	f, _ = failNTimes(5)
+	err = WorkerPoolFromSliceE(ctx, items, nWorkers, f, WithRetry(RetryPolicy{MaxAttempts: 3}))
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Len(poolErrs.Errors, len(items))
+	for _, pe := range poolErrs.Errors {
+		s.Equal(3, pe.Attempts)
+		s.ErrorIs(pe, io.ErrUnexpectedEOF)
+	}
+}
+
+func (s *RetrySuite) TestWorkerPoolWithRetryJob() {
+	ctx := context.Background()
+	f, _ := failNTimes(5)
+	err := WorkerPoolFromSliceE(ctx, []int{0}, 1, RetryJob(f, RetryPolicy{MaxAttempts: 4}))
+
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal(4, poolErrs.Errors[0].Attempts)
+}
+
+func (s *RetrySuite) TestBackoff() {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	s.Equal(10*time.Millisecond, policy.backoff(1))
+	s.Equal(20*time.Millisecond, policy.backoff(2))
+	s.Equal(40*time.Millisecond, policy.backoff(3))
+	s.Equal(50*time.Millisecond, policy.backoff(4))
+	s.Equal(50*time.Millisecond, policy.backoff(100))
+
+	// The cap is applied to the initial backoff as well
+	policy = RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	s.Equal(5*time.Millisecond, policy.backoff(1))
+
+	policy = RetryPolicy{InitialBackoff: 10 * time.Millisecond, Multiplier: 3}
+	s.Equal(90*time.Millisecond, policy.backoff(3))
+
+	// Without a cap, the backoff stops growing at the longest Duration rather than overflowing
+	policy = RetryPolicy{InitialBackoff: time.Second}
+	s.Equal(time.Duration(math.MaxInt64), policy.backoff(100))
+	s.Equal(time.Duration(math.MaxInt64), policy.backoff(10000))
+
+	policy = RetryPolicy{InitialBackoff: 100 * time.Millisecond, Jitter: 0.5}
+	for ii := 0; ii < 100; ii++ {
+		backoff := policy.backoff(1)
+		s.GreaterOrEqual(backoff, 50*time.Millisecond)
+		s.LessOrEqual(backoff, 100*time.Millisecond)
+	}
+}
+
+func (s *RetrySuite) TestWorkerPoolWithResultsAndRetry() {
+	ctx := context.Background()
+	N := 5
+	itemChan := make(chan int, N)
+	LoadChannel(itemChan, generateSeries(N)...)
+	close(itemChan)
+
+	// Every item succeeds on its second attempt, except for the last which always fails
+	f, _ := failNTimes(1)
+	job := func(ctx context.Context, v int) (int, error) {
+		if v == N-1 {
+			return 0, fmt.Errorf("%d", v)
+		}
+		return v, f(ctx, v)
+	}
+	out := make(chan int, N)
+	errs := WorkerPoolFromChanWithResults(ctx, itemChan, 2, job, out, WithRetry(RetryPolicy{MaxAttempts: 2}))
+	close(out)
+
+	s.Equal([]int{0, 1, 2, 3}, ChannelToSlice(out))
+	s.Equal([]error{fmt.Errorf("4")}, errs)
+}
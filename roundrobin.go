@@ -1,5 +1,7 @@
 package simpleflow
 
+import "context"
+
 // RoundRobin reads from the `from` channel and distributes the values in a round-robin fashion to the `to` channels.
 func RoundRobin[T any](from <-chan T, to ...chan<- T) {
 	if len(to) == 0 {
@@ -13,3 +15,24 @@ func RoundRobin[T any](from <-chan T, to ...chan<- T) {
 	}
 
 }
+
+// RoundRobinCtx reads from the `from` channel and distributes the values in a round-robin fashion to the `to` channels
+// until `from` is closed or the context is cancelled. It returns the number of values that were distributed, along
+// with the context error if the context was cancelled before `from` was closed.
+func RoundRobinCtx[T any](ctx context.Context, from <-chan T, to ...chan<- T) (int, error) {
+	if len(to) == 0 {
+		return 0, nil
+	}
+
+	var count int
+	for {
+		v, ok, err := recvCtx(ctx, from)
+		if err != nil || !ok {
+			return count, err
+		}
+		if !sendCtx(ctx, to[count%len(to)], v) {
+			return count, ctx.Err()
+		}
+		count++
+	}
+}
@@ -1,7 +1,9 @@
 package simpleflow
 
 import (
+	"context"
 	"github.com/stretchr/testify/suite"
+	"runtime"
 	"testing"
 )
 
@@ -43,3 +45,43 @@ func (s *RoundRobinSuite) TestRoundRobinEmptyTo() {
 
 	RoundRobin(source)
 }
+
+func (s *RoundRobinSuite) TestRoundRobinCtx() {
+	ctx := context.Background()
+	N := 9
+	source := make(chan int, N)
+	LoadChannel(source, generateSeries(N)...)
+	close(source)
+
+	fanoutSink1 := make(chan int, N)
+	fanoutSink2 := make(chan int, N)
+	n, err := RoundRobinCtx(ctx, source, fanoutSink1, fanoutSink2)
+	s.NoError(err)
+	s.Equal(N, n)
+	CloseManyWriters(fanoutSink1, fanoutSink2)
+	s.Equal([]int{0, 2, 4, 6, 8}, ChannelToSlice(fanoutSink1))
+	s.Equal([]int{1, 3, 5, 7}, ChannelToSlice(fanoutSink2))
+
+	n, err = RoundRobinCtx(ctx, source)
+	s.NoError(err)
+	s.Equal(0, n)
+}
+
+func (s *RoundRobinSuite) TestRoundRobinCtxCancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan int, 3)
+	LoadChannel(source, 1, 2, 3)
+
+	// The second sink has no buffer and no reader so the second value is never sent
+	fanoutSink1 := make(chan int, 3)
+	fanoutSink2 := make(chan int)
+	go func() {
+		for len(fanoutSink1) == 0 {
+			runtime.Gosched()
+		}
+		cancel()
+	}()
+	n, err := RoundRobinCtx(ctx, source, fanoutSink1, fanoutSink2)
+	s.ErrorIs(err, context.Canceled)
+	s.Equal(1, n)
+}
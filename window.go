@@ -0,0 +1,301 @@
+package simpleflow
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	simpletime "github.com/lobocv/simpleflow/time"
+)
+
+// Window is a group of items read from a channel along with the bounds of the window
+type Window[T any] struct {
+	simpletime.Range
+	Items []T
+}
+
+// TumblingCountWindow reads from a channel and pushes windows of `size` consecutive items onto the `to` channel until
+// `items` is closed or the context is cancelled. The bounds of each window are the timestamps of its first and last
+// items. Once `items` is closed, the remaining items are pushed as a final, smaller window. A `size` less than 1 is
+// treated as 1.
+//
+// The timestamp of each item is given by `timestamp`. If `timestamp` is nil, the time each item is received is used.
+// It returns the number of windows that were pushed, along with the context error if the context was cancelled
+// before `items` was closed. The open window is dropped when the context is cancelled.
+func TumblingCountWindow[T any](ctx context.Context, items <-chan T, size int, timestamp func(T) time.Time, to chan<- Window[T]) (int, error) {
+	if size < 1 {
+		size = 1
+	}
+	return runWindows[T](ctx, items, timestamp, &countWindows[T]{size: size}, to)
+}
+
+// TumblingTimeWindow reads from a channel and groups the items into consecutive windows of duration `length` by
+// timestamp. Each window covers the half-open time range [Start, End), with Start aligned to a multiple of `length`
+// since the zero time, and windows without items are not pushed. It panics if `length` is not positive. See
+// SlidingTimeWindow for how windows are completed.
+func TumblingTimeWindow[T any](ctx context.Context, items <-chan T, length time.Duration, timestamp func(T) time.Time, to chan<- Window[T]) (int, error) {
+	return SlidingTimeWindow(ctx, items, length, length, timestamp, to)
+}
+
+// SlidingTimeWindow reads from a channel and groups the items into windows of duration `length` which start every
+// `hop` by timestamp. Each window covers the half-open time range [Start, End), with Start aligned to a multiple of
+// `hop` since the zero time, so an item belongs to every window whose range contains its timestamp. A `hop` greater
+// than `length` leaves gaps between the windows and the items in the gaps are dropped. Windows without items are not
+// pushed. It panics if `length` or `hop` is not positive.
+//
+// The timestamp of each item is given by `timestamp`, and items are expected in timestamp order. A window is pushed
+// onto the `to` channel once an item with a timestamp at or past its end is read. An item that only belongs to
+// windows that were already pushed is dropped. If `timestamp` is nil, the time each item is received is used and
+// windows are also pushed once the current time passes their end. Once `items` is closed, the windows that are still
+// open are pushed.
+//
+// It returns the number of windows that were pushed, along with the context error if the context was cancelled
+// before `items` was closed. The open windows are dropped when the context is cancelled.
+func SlidingTimeWindow[T any](ctx context.Context, items <-chan T, length, hop time.Duration, timestamp func(T) time.Time, to chan<- Window[T]) (int, error) {
+	if length <= 0 || hop <= 0 {
+		panic("simpleflow: the length and hop of a time window must be positive")
+	}
+	return runWindows[T](ctx, items, timestamp, &timeWindows[T]{length: length, hop: hop}, to)
+}
+
+// SessionWindow reads from a channel and groups the items into sessions that are separated by at least `gap` without
+// items. The bounds of each session are the timestamps of its first and last items. It panics if `gap` is not
+// positive.
+//
+// The timestamp of each item is given by `timestamp`. A session is pushed onto the `to` channel once an item with a
+// timestamp at least `gap` after its last item is read. If `timestamp` is nil, the time each item is received is used
+// and a session is also pushed once no item has been received for `gap`. Once `items` is closed, the open session is
+// pushed.
+//
+// It returns the number of sessions that were pushed, along with the context error if the context was cancelled
+// before `items` was closed. The open session is dropped when the context is cancelled.
+func SessionWindow[T any](ctx context.Context, items <-chan T, gap time.Duration, timestamp func(T) time.Time, to chan<- Window[T]) (int, error) {
+	if gap <= 0 {
+		panic("simpleflow: the gap of a session window must be positive")
+	}
+	return runWindows[T](ctx, items, timestamp, &sessionWindows[T]{gap: gap}, to)
+}
+
+// windower assigns items to windows and decides when the windows are complete
+type windower[T any] interface {
+	// add adds an item with timestamp `t` to its windows and returns the windows that it completes
+	add(v T, t time.Time) []Window[T]
+	// expire returns the windows that are complete at time `t`
+	expire(t time.Time) []Window[T]
+	// deadline returns the time at which the next window is complete, or false if there is no open window or the
+	// windows are not complete at a particular time
+	deadline() (time.Time, bool)
+	// flush returns the windows that are still open
+	flush() []Window[T]
+}
+
+// runWindows reads from `items`, assigns them to windows with `w` and pushes the complete windows onto the `to`
+// channel. It returns the number of windows pushed.
+func runWindows[T any](ctx context.Context, items <-chan T, timestamp func(T) time.Time, w windower[T], to chan<- Window[T]) (int, error) {
+	// Without timestamps, windows need to be completed by a timer since no later item may arrive to complete them
+	processingTime := timestamp == nil
+	if processingTime {
+		timestamp = func(T) time.Time {
+			return time.Now()
+		}
+	}
+
+	var n int
+	push := func(windows []Window[T]) bool {
+		for _, win := range windows {
+			if !sendCtx(ctx, to, win) {
+				return false
+			}
+			n++
+		}
+		return true
+	}
+
+	// timeout fires at `timerDeadline`, the time at which the next window is complete. It is nil when there is no
+	// open window or the windows are completed by timestamp.
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	var timerDeadline time.Time
+	stopTimer := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+	}
+	defer stopTimer()
+
+	for {
+		// Check for cancellation first since select picks randomly between ready cases
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if processingTime {
+			d, ok := w.deadline()
+			if timer != nil && (!ok || !d.Equal(timerDeadline)) {
+				stopTimer()
+			}
+			if ok && timer == nil {
+				timer = time.NewTimer(time.Until(d))
+				timeout, timerDeadline = timer.C, d
+			}
+		}
+
+		select {
+		case v, ok := <-items:
+			if !ok {
+				if !push(w.flush()) {
+					return n, ctx.Err()
+				}
+				return n, nil
+			}
+			t := timestamp(v)
+			if !push(w.expire(t)) || !push(w.add(v, t)) {
+				return n, ctx.Err()
+			}
+		case now := <-timeout:
+			timer, timeout = nil, nil
+			if !push(w.expire(now)) {
+				return n, ctx.Err()
+			}
+		case <-ctx.Done():
+			return n, ctx.Err()
+		}
+	}
+}
+
+// countWindows is a windower for tumbling windows of a fixed number of items
+type countWindows[T any] struct {
+	size   int
+	window Window[T]
+}
+
+func (c *countWindows[T]) add(v T, t time.Time) []Window[T] {
+	if len(c.window.Items) == 0 {
+		c.window.Start = t
+	}
+	c.window.End = t
+	c.window.Items = append(c.window.Items, v)
+	if len(c.window.Items) < c.size {
+		return nil
+	}
+	return c.flush()
+}
+
+func (c *countWindows[T]) expire(time.Time) []Window[T] {
+	return nil
+}
+
+func (c *countWindows[T]) deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c *countWindows[T]) flush() []Window[T] {
+	if len(c.window.Items) == 0 {
+		return nil
+	}
+	win := c.window
+	c.window = Window[T]{}
+	return []Window[T]{win}
+}
+
+// timeWindows is a windower for windows of a fixed duration which start at a fixed interval
+type timeWindows[T any] struct {
+	length, hop time.Duration
+	// open holds the windows that have items and are not complete, ordered by start time
+	open []Window[T]
+	// pushed is the start of the last window that was completed. Windows that start at or before it are not reopened.
+	pushed *time.Time
+}
+
+func (w *timeWindows[T]) add(v T, t time.Time) []Window[T] {
+	for start := t.Truncate(w.hop); start.Add(w.length).After(t); start = start.Add(-w.hop) {
+		if w.pushed != nil && !start.After(*w.pushed) {
+			break
+		}
+		ii := sort.Search(len(w.open), func(ii int) bool {
+			return !w.open[ii].Start.Before(start)
+		})
+		if ii == len(w.open) || !w.open[ii].Start.Equal(start) {
+			w.open = append(w.open, Window[T]{})
+			copy(w.open[ii+1:], w.open[ii:])
+			w.open[ii] = Window[T]{Range: simpletime.Range{Start: start, End: start.Add(w.length)}}
+		}
+		w.open[ii].Items = append(w.open[ii].Items, v)
+	}
+	return nil
+}
+
+func (w *timeWindows[T]) expire(t time.Time) []Window[T] {
+	// All windows have the same length so they end in the same order that they start
+	var ii int
+	for ii < len(w.open) && !w.open[ii].End.After(t) {
+		ii++
+	}
+	return w.take(ii)
+}
+
+func (w *timeWindows[T]) deadline() (time.Time, bool) {
+	if len(w.open) == 0 {
+		return time.Time{}, false
+	}
+	return w.open[0].End, true
+}
+
+func (w *timeWindows[T]) flush() []Window[T] {
+	return w.take(len(w.open))
+}
+
+// take removes the first `n` open windows and returns them
+func (w *timeWindows[T]) take(n int) []Window[T] {
+	if n == 0 {
+		return nil
+	}
+	windows := w.open[:n:n]
+	w.open = w.open[n:]
+	last := windows[n-1].Start
+	w.pushed = &last
+	return windows
+}
+
+// sessionWindows is a windower for windows which are separated by a minimum gap without items
+type sessionWindows[T any] struct {
+	gap     time.Duration
+	session *Window[T]
+}
+
+func (s *sessionWindows[T]) add(v T, t time.Time) []Window[T] {
+	if s.session == nil {
+		s.session = &Window[T]{Range: simpletime.Range{Start: t, End: t}}
+	}
+	if t.Before(s.session.Start) {
+		s.session.Start = t
+	}
+	if t.After(s.session.End) {
+		s.session.End = t
+	}
+	s.session.Items = append(s.session.Items, v)
+	return nil
+}
+
+func (s *sessionWindows[T]) expire(t time.Time) []Window[T] {
+	if d, ok := s.deadline(); ok && !t.Before(d) {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *sessionWindows[T]) deadline() (time.Time, bool) {
+	if s.session == nil {
+		return time.Time{}, false
+	}
+	return s.session.End.Add(s.gap), true
+}
+
+func (s *sessionWindows[T]) flush() []Window[T] {
+	if s.session == nil {
+		return nil
+	}
+	win := *s.session
+	s.session = nil
+	return []Window[T]{win}
+}
@@ -0,0 +1,304 @@
+package simpleflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	simpletime "github.com/lobocv/simpleflow/time"
+	"github.com/stretchr/testify/suite"
+)
+
+type WindowSuite struct {
+	suite.Suite
+}
+
+func TestWindow(t *testing.T) {
+	s := new(WindowSuite)
+	suite.Run(t, s)
+}
+
+// windowBase is the time from which the test items are offset
+var windowBase = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// secondsTimestamp returns the timestamp of a test item, which is its number of seconds after windowBase
+func secondsTimestamp(v int) time.Time {
+	return windowBase.Add(time.Duration(v) * time.Second)
+}
+
+// secondsRange returns the range between two offsets in seconds from windowBase
+func secondsRange(start, end int) simpletime.Range {
+	return simpletime.Range{Start: secondsTimestamp(start), End: secondsTimestamp(end)}
+}
+
+// closedChan returns a closed channel holding the given items
+func closedChan(items ...int) chan int {
+	ch := make(chan int, len(items))
+	LoadChannel(ch, items...)
+	close(ch)
+	return ch
+}
+
+func (s *WindowSuite) TestTumblingCountWindow() {
+	ctx := context.Background()
+	out := make(chan Window[int], 10)
+	n, err := TumblingCountWindow(ctx, closedChan(0, 1, 2, 3, 4, 5, 6), 3, secondsTimestamp, out)
+	s.NoError(err)
+	s.Equal(3, n)
+	close(out)
+	s.Equal([]Window[int]{
+		{Range: secondsRange(0, 2), Items: []int{0, 1, 2}},
+		{Range: secondsRange(3, 5), Items: []int{3, 4, 5}},
+		{Range: secondsRange(6, 6), Items: []int{6}},
+	}, ChannelToSlice(out))
+
+	s.Run("size less than one", func() {
+		out := make(chan Window[int], 10)
+		n, err := TumblingCountWindow(ctx, closedChan(0, 1), 0, secondsTimestamp, out)
+		s.NoError(err)
+		s.Equal(2, n)
+	})
+}
+
+func (s *WindowSuite) TestTumblingTimeWindow() {
+	ctx := context.Background()
+	out := make(chan Window[int], 10)
+	n, err := TumblingTimeWindow(ctx, closedChan(1, 5, 10, 25, 29, 31), 10*time.Second, secondsTimestamp, out)
+	s.NoError(err)
+	s.Equal(4, n)
+	close(out)
+
+	// Windows without items are skipped and an item at the end of a window belongs to the next window
+	s.Equal([]Window[int]{
+		{Range: secondsRange(0, 10), Items: []int{1, 5}},
+		{Range: secondsRange(10, 20), Items: []int{10}},
+		{Range: secondsRange(20, 30), Items: []int{25, 29}},
+		{Range: secondsRange(30, 40), Items: []int{31}},
+	}, ChannelToSlice(out))
+}
+
+func (s *WindowSuite) TestSlidingTimeWindow() {
+	ctx := context.Background()
+
+	s.Run("overlapping", func() {
+		out := make(chan Window[int], 10)
+		n, err := SlidingTimeWindow(ctx, closedChan(1, 7, 12), 10*time.Second, 5*time.Second, secondsTimestamp, out)
+		s.NoError(err)
+		s.Equal(4, n)
+		close(out)
+		s.Equal([]Window[int]{
+			{Range: secondsRange(-5, 5), Items: []int{1}},
+			{Range: secondsRange(0, 10), Items: []int{1, 7}},
+			{Range: secondsRange(5, 15), Items: []int{7, 12}},
+			{Range: secondsRange(10, 20), Items: []int{12}},
+		}, ChannelToSlice(out))
+	})
+
+	s.Run("gaps between windows", func() {
+		out := make(chan Window[int], 10)
+		n, err := SlidingTimeWindow(ctx, closedChan(3, 7, 12), 5*time.Second, 10*time.Second, secondsTimestamp, out)
+		s.NoError(err)
+		s.Equal(2, n)
+		close(out)
+		s.Equal([]Window[int]{
+			{Range: secondsRange(0, 5), Items: []int{3}},
+			{Range: secondsRange(10, 15), Items: []int{12}},
+		}, ChannelToSlice(out))
+	})
+
+	s.Run("late items", func() {
+		out := make(chan Window[int], 10)
+		// The window starting at 0 is pushed when 12 is read, so 3 is dropped while 6 is added to the open window
+		// starting at 5
+		items := closedChan(1, 12, 3, 6)
+		n, err := SlidingTimeWindow(ctx, items, 10*time.Second, 5*time.Second, secondsTimestamp, out)
+		s.NoError(err)
+		s.Equal(4, n)
+		close(out)
+		s.Equal([]Window[int]{
+			{Range: secondsRange(-5, 5), Items: []int{1}},
+			{Range: secondsRange(0, 10), Items: []int{1}},
+			{Range: secondsRange(5, 15), Items: []int{12, 6}},
+			{Range: secondsRange(10, 20), Items: []int{12}},
+		}, ChannelToSlice(out))
+	})
+
+	s.Run("invalid durations", func() {
+		out := make(chan Window[int])
+		s.Panics(func() {
+			_, _ = SlidingTimeWindow(ctx, closedChan(), 0, time.Second, secondsTimestamp, out)
+		})
+		s.Panics(func() {
+			_, _ = TumblingTimeWindow(ctx, closedChan(), -time.Second, secondsTimestamp, out)
+		})
+		s.Panics(func() {
+			_, _ = SlidingTimeWindow(ctx, closedChan(), time.Second, 0, secondsTimestamp, out)
+		})
+	})
+}
+
+func (s *WindowSuite) TestSessionWindow() {
+	ctx := context.Background()
+	out := make(chan Window[int], 10)
+	n, err := SessionWindow(ctx, closedChan(0, 2, 6, 11, 13, 20), 5*time.Second, secondsTimestamp, out)
+	s.NoError(err)
+	s.Equal(3, n)
+	close(out)
+
+	// A gap of exactly 5 seconds starts a new session
+	s.Equal([]Window[int]{
+		{Range: secondsRange(0, 6), Items: []int{0, 2, 6}},
+		{Range: secondsRange(11, 13), Items: []int{11, 13}},
+		{Range: secondsRange(20, 20), Items: []int{20}},
+	}, ChannelToSlice(out))
+
+	s.Run("late items", func() {
+		out := make(chan Window[int], 10)
+		n, err := SessionWindow(ctx, closedChan(4, 2, 6), 5*time.Second, secondsTimestamp, out)
+		s.NoError(err)
+		s.Equal(1, n)
+		close(out)
+		s.Equal([]Window[int]{{Range: secondsRange(2, 6), Items: []int{4, 2, 6}}}, ChannelToSlice(out))
+	})
+
+	s.Run("invalid gap", func() {
+		s.Panics(func() {
+			_, _ = SessionWindow(ctx, closedChan(), 0, secondsTimestamp, make(chan Window[int]))
+		})
+	})
+}
+
+func (s *WindowSuite) TestProcessingTime() {
+	ctx := context.Background()
+
+	// run starts `f` in the background and returns a channel with its result
+	type result struct {
+		n   int
+		err error
+	}
+	run := func(f func() (int, error)) chan result {
+		done := make(chan result, 1)
+		go func() {
+			n, err := f()
+			done <- result{n: n, err: err}
+		}()
+		return done
+	}
+
+	s.Run("session", func() {
+		items := make(chan int)
+		out := make(chan Window[int])
+		done := run(func() (int, error) {
+			return SessionWindow(ctx, items, 50*time.Millisecond, nil, out)
+		})
+
+		// The sessions are pushed once the input is idle without waiting for more items
+		items <- 1
+		items <- 2
+		win := <-out
+		s.Equal([]int{1, 2}, win.Items)
+		s.False(win.End.Before(win.Start))
+		items <- 3
+		s.Equal([]int{3}, (<-out).Items)
+
+		close(items)
+		r := <-done
+		s.NoError(r.err)
+		s.Equal(2, r.n)
+	})
+
+	s.Run("tumbling", func() {
+		items := make(chan int)
+		out := make(chan Window[int])
+		done := run(func() (int, error) {
+			return TumblingTimeWindow(ctx, items, 20*time.Millisecond, nil, out)
+		})
+
+		before := time.Now()
+		items <- 1
+		win := <-out
+		s.Equal([]int{1}, win.Items)
+		s.Equal(20*time.Millisecond, win.Duration())
+		s.True(win.End.After(before))
+		s.False(time.Now().Before(win.End))
+
+		close(items)
+		r := <-done
+		s.NoError(r.err)
+		s.Equal(1, r.n)
+	})
+
+	s.Run("count", func() {
+		items := make(chan int)
+		out := make(chan Window[int], 1)
+		done := run(func() (int, error) {
+			return TumblingCountWindow(ctx, items, 2, nil, out)
+		})
+		before := time.Now()
+		LoadChannel(items, 1, 2)
+		win := <-out
+		s.Equal([]int{1, 2}, win.Items)
+		s.False(win.Start.Before(before))
+		s.False(win.End.Before(win.Start))
+
+		close(items)
+		r := <-done
+		s.NoError(r.err)
+		s.Equal(1, r.n)
+	})
+}
+
+func (s *WindowSuite) TestCancelled() {
+	s.Run("while waiting for items", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		items := make(chan int, 3)
+		LoadChannel(items, 1, 2, 3)
+		out := make(chan Window[int], 10)
+		go func() {
+			for len(items) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+		}()
+
+		// The open window is dropped
+		n, err := TumblingCountWindow(ctx, items, 2, secondsTimestamp, out)
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(1, n)
+	})
+
+	s.Run("while pushing windows", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		out := make(chan Window[int])
+		go func() {
+			<-out
+			cancel()
+		}()
+		n, err := TumblingTimeWindow(ctx, closedChan(1, 11, 21), 10*time.Second, secondsTimestamp, out)
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(1, n)
+	})
+
+	s.Run("while pushing the final windows", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		n, err := SessionWindow(ctx, closedChan(1), time.Second, secondsTimestamp, make(chan Window[int]))
+		s.ErrorIs(err, context.Canceled)
+		s.Equal(0, n)
+	})
+
+	s.Run("while pushing a timed out window", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		items := make(chan int)
+		out := make(chan Window[int])
+		done := make(chan error, 1)
+		go func() {
+			_, err := SessionWindow(ctx, items, time.Millisecond, nil, out)
+			done <- err
+		}()
+		items <- 1
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		s.ErrorIs(<-done, context.Canceled)
+	})
+}
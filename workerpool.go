@@ -0,0 +1,281 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolClosed is returned when submitting an item to a Pool that is shut down or stopped
+	ErrPoolClosed = errors.New("pool is closed")
+	// ErrQueueFull is returned by TrySubmit when the queue of a Pool is full
+	ErrQueueFull = errors.New("pool queue is full")
+)
+
+// Pool is a long-lived worker pool which processes items as they are submitted. Unlike the WorkerPoolFrom*
+// functions, a Pool is not tied to a collection of items and can be reused until it is shut down.
+type Pool[T any] struct {
+	cfg    poolConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  chan poolJob[T]
+
+	// closing is closed when the pool stops accepting new items. submitMu prevents the queue from being
+	// closed while an item is being submitted.
+	closing   chan struct{}
+	closeOnce sync.Once
+	submitMu  sync.RWMutex
+
+	work       func(context.Context, poolJob[T]) error
+	deadLetter func(*PoolError[T])
+	nErrors    int64
+	window     jobWindow
+
+	// mu guards the fields below. cond is signalled when pending reaches zero or the workers exit.
+	mu        sync.Mutex
+	cond      *sync.Cond
+	nextIndex int
+	pending   int
+	errors    []*PoolError[T]
+	// stops holds a channel for each running worker which is closed to stop the worker after its current job.
+	// Once all workers have exited, `exited` is closed and no more workers can be started.
+	stops   []chan struct{}
+	running int
+	exited  chan struct{}
+}
+
+// NewPool starts a Pool of `nWorkers` workers which call `f` for each submitted item. Submitted items are held in a
+// queue of size `queueSize` until a worker is available. The workers stop when `ctx` is cancelled.
+func NewPool[T any](ctx context.Context, nWorkers, queueSize int, f Job[T], opts ...PoolOption) *Pool[T] {
+	p := &Pool[T]{
+		cfg:     newPoolConfig(opts),
+		queue:   make(chan poolJob[T], queueSize),
+		closing: make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	p.deadLetter = deadLetterHandler[T](p.cfg)
+	// Check the item type of the weight function before starting so that a mismatch panics in the caller
+	weigherFor[T](p.cfg)
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.cond = sync.NewCond(&p.mu)
+
+	p.cfg.observeQueue(func() int {
+		return len(p.queue)
+	})
+
+	// Keep track of the number of pending items so that callers can Wait() for them
+	p.cfg.afterJob = func(int, error) {
+		p.done(1)
+	}
+
+	// Record the latency and outcome of each job so that the number of workers can be adapted
+	p.work = func(ctx context.Context, j poolJob[T]) error {
+		start := time.Now()
+		err := f(ctx, j.item)
+		p.window.observe(time.Since(start), err)
+		return err
+	}
+	p.SetWorkers(nWorkers)
+
+	return p
+}
+
+// SetWorkers changes the number of workers in the pool. When the number of workers is reduced, the workers that are
+// removed finish their current job before exiting. A value of `n` less than 1 is treated as 1. It has no effect once
+// the pool is stopped.
+func (p *Pool[T]) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hasExited() {
+		return
+	}
+	for len(p.stops) < n {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		p.running++
+		go p.runWorker(stop)
+	}
+	for len(p.stops) > n {
+		close(p.stops[len(p.stops)-1])
+		p.stops = p.stops[:len(p.stops)-1]
+	}
+}
+
+// Workers returns the number of workers in the pool
+func (p *Pool[T]) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+// runWorker processes items from the queue until `stop` is closed or the pool is stopped
+func (p *Pool[T]) runWorker(stop chan struct{}) {
+	poolWorker(p.ctx, p.cfg, p.queue, stop, p.work, p.onError)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	if p.running == 0 {
+		// Wake up any callers of Wait() since the pending items will never be processed
+		close(p.exited)
+		p.cond.Broadcast()
+	}
+}
+
+// Submit adds an item to the queue of the pool. If the queue is full, it blocks until there is space in the queue,
+// the context is cancelled or the pool is closed.
+func (p *Pool[T]) Submit(ctx context.Context, item T) error {
+	return p.submit(ctx, item, true)
+}
+
+// TrySubmit adds an item to the queue of the pool without blocking. It returns ErrQueueFull if the queue is full.
+func (p *Pool[T]) TrySubmit(item T) error {
+	return p.submit(context.Background(), item, false)
+}
+
+// Wait blocks until all submitted items are processed, or the pool is stopped. It returns a *PoolErrors[T] for the
+// jobs that failed since the previous call to Wait, or nil if no jobs failed.
+func (p *Pool[T]) Wait() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.pending > 0 && !p.hasExited() {
+		p.cond.Wait()
+	}
+	return p.takeErrors()
+}
+
+// Shutdown stops the pool from accepting new items and waits for the queued and in-flight items to be processed.
+// It returns the same errors as Wait. If the context is cancelled before all items are processed, Shutdown returns
+// the context error and the pool continues to drain in the background. Call Stop to abandon the remaining items.
+func (p *Pool[T]) Shutdown(ctx context.Context) error {
+	p.close()
+
+	select {
+	case <-p.exited:
+		p.cancel()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.takeErrors()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops the pool from accepting new items and cancels the context of the in-flight jobs. It waits for the
+// workers to exit and returns the queued items that were abandoned.
+func (p *Pool[T]) Stop() []T {
+	p.close()
+	p.cancel()
+	<-p.exited
+
+	// The queue is closed so the remaining items can be drained without blocking
+	var abandoned []T
+	for j := range p.queue {
+		abandoned = append(abandoned, j.item)
+	}
+	p.done(len(abandoned))
+
+	return abandoned
+}
+
+// submit adds an item to the queue, optionally blocking until there is space in the queue
+func (p *Pool[T]) submit(ctx context.Context, item T, block bool) error {
+	// Hold the read lock so that the queue is not closed while sending to it
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	// Check for closure first since select picks randomly between ready cases
+	select {
+	case <-p.closing:
+		return ErrPoolClosed
+	case <-p.ctx.Done():
+		return ErrPoolClosed
+	default:
+	}
+
+	// Count the item as pending before it is queued so that it cannot be processed before it is counted
+	p.mu.Lock()
+	j := poolJob[T]{index: p.nextIndex, item: item}
+	p.nextIndex++
+	p.pending++
+	p.mu.Unlock()
+
+	if !block {
+		select {
+		case p.queue <- j:
+			return nil
+		default:
+			p.done(1)
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case p.queue <- j:
+		return nil
+	case <-p.closing:
+		p.done(1)
+		return ErrPoolClosed
+	case <-p.ctx.Done():
+		p.done(1)
+		return ErrPoolClosed
+	case <-ctx.Done():
+		p.done(1)
+		return ctx.Err()
+	}
+}
+
+// close stops the pool from accepting new items and closes the queue so that the workers exit once it is drained
+func (p *Pool[T]) close() {
+	p.closeOnce.Do(func() {
+		// Closing this channel first unblocks calls to Submit that are waiting for space in the queue
+		close(p.closing)
+		p.submitMu.Lock()
+		close(p.queue)
+		p.submitMu.Unlock()
+	})
+}
+
+// onError records the error of a failed job and cancels the pool if the maximum number of errors is reached
+func (p *Pool[T]) onError(err *PoolError[T]) {
+	if p.cfg.reachedMaxErrors(&p.nErrors) {
+		p.cancel()
+	}
+	p.deadLetter(err)
+	p.mu.Lock()
+	p.errors = append(p.errors, err)
+	p.mu.Unlock()
+}
+
+// done marks `n` pending items as finished
+func (p *Pool[T]) done(n int) {
+	p.mu.Lock()
+	p.pending -= n
+	if p.pending == 0 {
+		p.cond.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+// hasExited reports whether all workers have exited
+func (p *Pool[T]) hasExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// takeErrors returns the errors recorded so far and resets them. It must be called with `mu` held.
+func (p *Pool[T]) takeErrors() error {
+	errs := p.errors
+	p.errors = nil
+	return newPoolErrors(errs, nil)
+}
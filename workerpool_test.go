@@ -0,0 +1,226 @@
+package simpleflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolSuite struct {
+	suite.Suite
+}
+
+func TestPool(t *testing.T) {
+	s := new(PoolSuite)
+	suite.Run(t, s)
+}
+
+// blockingJob returns a job that signals on `started` when it starts and blocks until `release` is closed
+func blockingJob(started chan<- int, release <-chan struct{}) Job[int] {
+	return func(ctx context.Context, v int) error {
+		started <- v
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *PoolSuite) TestSubmitAndWait() {
+	ctx := context.Background()
+	out := NewSyncMap(map[int]int{})
+	f := func(_ context.Context, v int) error {
+		out.Set(v, v*v)
+		if v == 3 {
+			return fmt.Errorf("%d", v)
+		}
+		return nil
+	}
+	pool := NewPool(ctx, 2, 2, f)
+
+	for ii := 0; ii < 6; ii++ {
+		s.NoError(pool.Submit(ctx, ii))
+	}
+	err := pool.Wait()
+	var poolErrs *PoolErrors[int]
+	s.Require().True(errors.As(err, &poolErrs))
+	s.Equal([]int{3}, poolErrs.Items())
+	s.Equal(map[int]int{0: 0, 1: 1, 2: 4, 3: 9, 4: 16, 5: 25}, out.m)
+
+	// The pool can be reused and errors are only reported once
+	s.NoError(pool.Submit(ctx, 6))
+	s.NoError(pool.Wait())
+	s.Equal(36, out.m[6])
+
+	s.NoError(pool.Shutdown(ctx))
+	s.ErrorIs(pool.Submit(ctx, 7), ErrPoolClosed)
+	s.ErrorIs(pool.TrySubmit(7), ErrPoolClosed)
+}
+
+func (s *PoolSuite) TestTrySubmitQueueFull() {
+	ctx := context.Background()
+	started := make(chan int, 1)
+	release := make(chan struct{})
+	pool := NewPool(ctx, 1, 1, blockingJob(started, release))
+
+	// The first item occupies the worker and the second fills the queue
+	s.NoError(pool.TrySubmit(0))
+	<-started
+	s.NoError(pool.TrySubmit(1))
+	s.ErrorIs(pool.TrySubmit(2), ErrQueueFull)
+
+	close(release)
+	s.NoError(pool.Wait())
+	s.Equal(1, <-started)
+	s.NoError(pool.Shutdown(ctx))
+}
+
+func (s *PoolSuite) TestSubmitBackpressure() {
+	ctx := context.Background()
+	started := make(chan int, 1)
+	release := make(chan struct{})
+	pool := NewPool(ctx, 1, 1, blockingJob(started, release))
+
+	s.NoError(pool.Submit(ctx, 0))
+	<-started
+	s.NoError(pool.Submit(ctx, 1))
+
+	// The queue is full so submitting blocks until the context expires
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(pool.Submit(timeoutCtx, 2), context.DeadlineExceeded)
+
+	// Submitting blocks until the pool is shut down
+	submitted := make(chan error)
+	go func() {
+		submitted <- pool.Submit(ctx, 3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		_ = pool.Shutdown(ctx)
+	}()
+	s.ErrorIs(<-submitted, ErrPoolClosed)
+
+	close(release)
+	s.NoError(pool.Wait())
+}
+
+func (s *PoolSuite) TestShutdownDrainsQueue() {
+	ctx := context.Background()
+	out := NewSyncMap(map[int]int{})
+	release := make(chan struct{})
+	f := func(_ context.Context, v int) error {
+		<-release
+		out.Set(v, v)
+		return nil
+	}
+	pool := NewPool(ctx, 1, 5, f)
+	for ii := 0; ii < 5; ii++ {
+		s.NoError(pool.Submit(ctx, ii))
+	}
+
+	// The deadline expires before the queue is drained
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(pool.Shutdown(timeoutCtx), context.DeadlineExceeded)
+
+	close(release)
+	s.NoError(pool.Shutdown(ctx))
+	s.Equal(map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 4}, out.m)
+}
+
+func (s *PoolSuite) TestStop() {
+	ctx := context.Background()
+	started := make(chan int, 1)
+	pool := NewPool(ctx, 1, 5, blockingJob(started, nil))
+	for ii := 0; ii < 5; ii++ {
+		s.NoError(pool.Submit(ctx, ii))
+	}
+	<-started
+
+	// The in-flight item is cancelled and the queued items are abandoned
+	s.Equal([]int{1, 2, 3, 4}, pool.Stop())
+	err := pool.Wait()
+	s.ErrorIs(err, context.Canceled)
+	s.ErrorIs(pool.Submit(ctx, 5), ErrPoolClosed)
+}
+
+func (s *PoolSuite) TestPoolFailFast() {
+	ctx := context.Background()
+	// The first item only fails once all items are submitted so that the pool is not cancelled while submitting
+	submitted := make(chan struct{})
+	release := make(chan struct{})
+	f := func(ctx context.Context, v int) error {
+		if v == 0 {
+			<-submitted
+			return fmt.Errorf("%d", v)
+		}
+		<-release
+		return nil
+	}
+	pool := NewPool(ctx, 1, 5, f, WithFailFast())
+	for ii := 0; ii < 3; ii++ {
+		s.NoError(pool.Submit(ctx, ii))
+	}
+	close(submitted)
+
+	// The pool is cancelled by the error so Wait returns without processing the remaining items
+	err := pool.Wait()
+	s.Error(err)
+	s.ErrorIs(pool.Submit(ctx, 3), ErrPoolClosed)
+	s.Len(pool.Stop(), 2)
+}
+
+func (s *PoolSuite) TestSetWorkers() {
+	ctx := context.Background()
+	started := make(chan int, 10)
+	// Each job waits for its own release so that releasing a job never lets a later one finish
+	releases := make([]chan struct{}, 6)
+	for ii := range releases {
+		releases[ii] = make(chan struct{})
+	}
+	f := func(ctx context.Context, v int) error {
+		return blockingJob(started, releases[v])(ctx, v)
+	}
+	pool := NewPool(ctx, 1, 10, f)
+	s.Equal(1, pool.Workers())
+
+	for ii := 0; ii < 6; ii++ {
+		s.NoError(pool.Submit(ctx, ii))
+	}
+	s.Equal(0, <-started)
+
+	// Growing the pool starts more jobs concurrently
+	pool.SetWorkers(3)
+	s.Equal(3, pool.Workers())
+	s.ElementsMatch([]int{1, 2}, []int{<-started, <-started})
+
+	// Shrinking the pool lets the in-flight jobs finish and only one job runs at a time afterwards
+	pool.SetWorkers(0)
+	s.Equal(1, pool.Workers())
+	for ii := 0; ii < 3; ii++ {
+		close(releases[ii])
+	}
+	s.Equal(3, <-started)
+	select {
+	case v := <-started:
+		s.Failf("unexpected job", "job %d started with a single worker busy", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	for ii := 3; ii < len(releases); ii++ {
+		close(releases[ii])
+	}
+	s.NoError(pool.Wait())
+
+	// Resizing a stopped pool has no effect
+	pool.Stop()
+	pool.SetWorkers(5)
+	s.Equal(1, pool.Workers())
+}